@@ -0,0 +1,97 @@
+package main
+
+import (
+	"io"
+	"testing"
+
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// testSetup parses the Go Regular font embedded in golang.org/x/image and
+// returns the default options.
+func testSetup(tb testing.TB) (*sfnt.Font, *options) {
+	tb.Helper()
+	f, err := sfnt.Parse(goregular.TTF)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	opts := &options{}
+	if _, err := newParser(opts).ParseArgs([]string{"-f", "goregular.ttf"}); err != nil {
+		tb.Fatal(err)
+	}
+	return f, opts
+}
+
+// bmpRunes returns every rune of the Basic Multilingual Plane the font has a glyph for.
+func bmpRunes(tb testing.TB, f *sfnt.Font) []rune {
+	tb.Helper()
+	var runes []rune
+	for v := rune(0x20); v <= 0xFFFF; v++ {
+		if v >= 0xD800 && v <= 0xDFFF {
+			continue
+		}
+		x, err := f.GlyphIndex(nil, v)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		if x != 0 {
+			runes = append(runes, v)
+		}
+	}
+	return runes
+}
+
+// BenchmarkRenderGlyph renders a single glyph into a reused cell. Run the
+// benchmarks with:
+//
+//	go test -run '^$' -bench . -benchmem
+func BenchmarkRenderGlyph(b *testing.B) {
+	f, opts := testSetup(b)
+	r, err := newRenderer(f, opts)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := r.glyph('@'); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGenerateASCII renders and writes the default ASCII font.
+func BenchmarkGenerateASCII(b *testing.B) {
+	f, opts := testSetup(b)
+	runes := asciiRunes()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		glyphs, err := renderGlyphs(f, runes, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := writeC(io.Discard, glyphs, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGenerateBMP renders and writes every glyph of the Basic
+// Multilingual Plane.
+func BenchmarkGenerateBMP(b *testing.B) {
+	f, opts := testSetup(b)
+	runes := bmpRunes(b, f)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		glyphs, err := renderGlyphs(f, runes, opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := writeC(io.Discard, glyphs, opts); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
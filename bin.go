@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The bin format starts with a header of binHeaderSize bytes, all numbers
+// little-endian:
+//
+//	0  4  magic "WSFB"
+//	4  1  version, 1
+//	5  1  bits per pixel
+//	6  1  flags, binIndexed if the index follows the header
+//	7  1  reserved, 0
+//	8  2  width in pixels
+//	10 2  height in rows
+//	12 4  number of glyphs
+//	16 4  first codepoint
+//
+// Without the index glyph i, the codepoint first + i, follows at
+// binHeaderSize + i * glyph bytes. With the index binIndexEntry bytes per
+// glyph follow, sorted by codepoint: the codepoint, the absolute offset of
+// the glyph in the file and its length, each as 4 bytes.
+const (
+	binMagic      = "WSFB"
+	binVersion    = 1
+	binIndexed    = 1
+	binHeaderSize = 20
+	binIndexEntry = 12
+)
+
+// writeBin writes the glyphs in the bin format.
+func writeBin(w io.Writer, glyphs []glyph, opts *options) error {
+	width, height := opts.storedCell()
+	runes := glyphRunes(glyphs)
+	if len(runes) == 0 {
+		return fmt.Errorf("bin: no glyphs")
+	}
+	var flags byte
+	if opts.BinIndexed {
+		flags |= binIndexed
+	} else {
+		for i, v := range runes {
+			if v != runes[0]+rune(i) {
+				return fmt.Errorf("bin: U+%04X leaves a gap in the codepoints, which requires bin-indexed", v)
+			}
+		}
+	}
+	b := &bytes.Buffer{}
+	b.WriteString(binMagic)
+	b.Write([]byte{binVersion, byte(opts.Bpp), flags, 0})
+	le := binary.LittleEndian
+	b.Write(le.AppendUint16(nil, uint16(width)))
+	b.Write(le.AppendUint16(nil, uint16(height)))
+	b.Write(le.AppendUint32(nil, uint32(len(glyphs))))
+	b.Write(le.AppendUint32(nil, uint32(runes[0])))
+	if opts.BinIndexed {
+		offset := binHeaderSize + binIndexEntry*len(glyphs)
+		for _, g := range glyphs {
+			b.Write(le.AppendUint32(nil, uint32(g.rune)))
+			b.Write(le.AppendUint32(nil, uint32(offset)))
+			b.Write(le.AppendUint32(nil, uint32(len(g.data))))
+			offset += len(g.data)
+		}
+	}
+	for _, g := range glyphs {
+		b.Write(g.data)
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+// binFont reads glyphs from a font in the bin format on demand, like
+// firmware seeking in external flash.
+type binFont struct {
+	r             io.ReaderAt
+	bpp           int
+	indexed       bool
+	width, height int
+	count         int
+	first         rune
+}
+
+// openBin reads the header of a font in the bin format.
+func openBin(r io.ReaderAt) (*binFont, error) {
+	h := make([]byte, binHeaderSize)
+	if _, err := r.ReadAt(h, 0); err != nil {
+		return nil, fmt.Errorf("bin header: %v", err)
+	}
+	if string(h[:4]) != binMagic || h[4] != binVersion {
+		return nil, fmt.Errorf("not a bin font of version %d", binVersion)
+	}
+	le := binary.LittleEndian
+	return &binFont{
+		r:       r,
+		bpp:     int(h[5]),
+		indexed: h[6]&binIndexed != 0,
+		width:   int(le.Uint16(h[8:])),
+		height:  int(le.Uint16(h[10:])),
+		count:   int(le.Uint32(h[12:])),
+		first:   rune(le.Uint32(h[16:])),
+	}, nil
+}
+
+// glyph reads the bitmap of v, seeking only to the index entries of a
+// binary search and to the glyph.
+func (f *binFont) glyph(v rune) ([]byte, error) {
+	size := (f.width*f.bpp + 7) / 8 * f.height
+	var offset int64
+	if !f.indexed {
+		if v < f.first || int(v-f.first) >= f.count {
+			return nil, fmt.Errorf("no glyph for U+%04X", v)
+		}
+		offset = int64(binHeaderSize + int(v-f.first)*size)
+	} else {
+		var entry [binIndexEntry]byte
+		var err error
+		i := sort.Search(f.count, func(i int) bool {
+			if _, rerr := f.r.ReadAt(entry[:], int64(binHeaderSize+i*binIndexEntry)); rerr != nil {
+				err = rerr
+				return true
+			}
+			return rune(binary.LittleEndian.Uint32(entry[:])) >= v
+		})
+		if err != nil {
+			return nil, fmt.Errorf("bin index: %v", err)
+		}
+		if i == f.count {
+			return nil, fmt.Errorf("no glyph for U+%04X", v)
+		}
+		if _, err := f.r.ReadAt(entry[:], int64(binHeaderSize+i*binIndexEntry)); err != nil {
+			return nil, fmt.Errorf("bin index: %v", err)
+		}
+		le := binary.LittleEndian
+		if rune(le.Uint32(entry[:])) != v {
+			return nil, fmt.Errorf("no glyph for U+%04X", v)
+		}
+		offset, size = int64(le.Uint32(entry[4:])), int(le.Uint32(entry[8:]))
+	}
+	data := make([]byte, size)
+	if _, err := f.r.ReadAt(data, offset); err != nil {
+		return nil, fmt.Errorf("glyph of U+%04X: %v", v, err)
+	}
+	return data, nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestBinRandomAccess writes fonts in the bin format with and without the
+// index and reads single glyphs back through openBin.
+func TestBinRandomAccess(t *testing.T) {
+	for _, indexed := range []bool{false, true} {
+		f, opts := testSetup(t)
+		opts.Format, opts.BinIndexed = "bin", indexed
+		runes := []rune("ABCDEFGH")
+		if indexed {
+			runes = []rune("!Aaz~é")
+		}
+		glyphs, err := renderGlyphs(f, runes, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		if err := write(&b, glyphs, opts); err != nil {
+			t.Fatal(err)
+		}
+		font, err := openBin(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := len(glyphs) - 1; i >= 0; i-- {
+			data, err := font.glyph(glyphs[i].rune)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(data, glyphs[i].data) {
+				t.Fatalf("indexed %v: the glyph of U+%04X differs", indexed, glyphs[i].rune)
+			}
+		}
+		if _, err := font.glyph('0'); err == nil {
+			t.Fatalf("indexed %v: got a glyph for a missing codepoint", indexed)
+		}
+	}
+}
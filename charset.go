@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// asciiRunes returns the printable ASCII runes covered by the classic sFONT table.
+func asciiRunes() []rune {
+	var runes []rune
+	for i := 32; i <= 126; i++ {
+		runes = append(runes, rune(i))
+	}
+	return runes
+}
+
+// selectRunes returns the sorted runes to render. Without any selection
+// flag these are the printable ASCII runes, or the runes of the locale if
+// one is configured, which any of the fonts has a glyph for. The base
+// codepoints of the variation sequences are always added, sequences of the
+// coverage file are appended to the variants of opts.
+func selectRunes(f *sfnt.Font, opts *options) ([]rune, error) {
+	set := map[rune]bool{}
+	if opts.CoverageFile != "" {
+		runes, seqs, err := readCoverage(string(opts.CoverageFile))
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range runes {
+			set[v] = true
+		}
+		opts.Variants = append(opts.Variants, seqs...)
+	}
+	if len(set) == 0 {
+		runes := asciiRunes()
+		if opts.Locale != "" {
+			var err error
+			if runes, err = localeRunes(append([]*sfnt.Font{f}, opts.fallbacks...), opts.Locale); err != nil {
+				return nil, err
+			}
+		}
+		for _, v := range runes {
+			set[v] = true
+		}
+	}
+	for _, s := range opts.Variants {
+		base, _, err := parseVariant(s)
+		if err != nil {
+			return nil, err
+		}
+		set[base] = true
+	}
+	runes := make([]rune, 0, len(set))
+	for v := range set {
+		runes = append(runes, v)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes, nil
+}
+
+// readCoverage reads a coverage file with one codepoint or variation
+// sequence per line, codepoints written as U+XXXX or bare hex. Everything
+// after a # is a comment. The base codepoints of the sequences are part of
+// the returned runes.
+func readCoverage(name string) (runes []rune, seqs []string, err error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	s := bufio.NewScanner(file)
+	for line := 1; s.Scan(); line++ {
+		text, _, _ := strings.Cut(s.Text(), "#")
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		if len(variantFields(text)) > 1 {
+			base, _, err := parseVariant(text)
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s:%d: %v", name, line, err)
+			}
+			runes, seqs = append(runes, base), append(seqs, text)
+			continue
+		}
+		v, err := parseCodepoint(text)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		runes = append(runes, v)
+	}
+	return runes, seqs, s.Err()
+}
+
+// parseCodepoint parses a codepoint written as U+XXXX, 0xXXXX or bare hex.
+func parseCodepoint(s string) (rune, error) {
+	for _, prefix := range []string{"U+", "u+", "0x", "0X"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil || v > 0x10FFFF {
+		return 0, fmt.Errorf("invalid codepoint %q", s)
+	}
+	return rune(v), nil
+}
+
+// parseHex parses a byte pattern like 0xFFFF or DEADBEEF.
+func parseHex(s string) ([]byte, error) {
+	s = strings.TrimPrefix(strings.TrimPrefix(s, "0x"), "0X")
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid hex byte pattern %q", s)
+	}
+	return b, nil
+}
+
+// contiguousASCII reports whether runes can be indexed the classic sFONT
+// way by subtracting ' ' from the rune.
+func contiguousASCII(runes []rune) bool {
+	for i, v := range runes {
+		if v != ' '+rune(i) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	commentRe = regexp.MustCompile(`(?s)//[^\n]*|/\*.*?\*/`)
+	numberRe  = regexp.MustCompile(`\b(0[xX][0-9a-fA-F]+|[0-9]+)\b`)
+	structRe  = regexp.MustCompile(`sFONT\s+FontCustom\s*=\s*\{\s*\w+\s*,\s*(\d+)\s*,\s*(\d+)`)
+	bppRe     = regexp.MustCompile(`#define\s+FontCustom_Bpp\s+(\d+)(?:\s*/\*\s*(\w+))?`)
+	nibbleRe  = regexp.MustCompile(`#define\s+FontCustom_LowNibbleFirst\s+([01])`)
+	fieldsRe  = regexp.MustCompile(`(?s)typedef\s+struct\s*\{(.*?)\}\s*FontCustom_GlyphInfo`)
+	fieldRe   = regexp.MustCompile(`\w+_t\s+(\w+)\s*;`)
+	strideRe  = regexp.MustCompile(`#define\s+FontCustom_GlyphStride\s+(\d+)`)
+	phasesRe  = regexp.MustCompile(`#define\s+FontCustom_Phases\s+(\d+)`)
+)
+
+// header is a generated C header read back for comparison.
+type header struct {
+	name   string
+	width  int
+	height int
+	glyphs map[rune]glyph
+}
+
+// arrayValues returns the numbers of the initializer of the array name in
+// src, which is free of comments.
+func arrayValues(src, name string) ([]int, bool) {
+	i := strings.Index(src, name+" []")
+	if i < 0 {
+		return nil, false
+	}
+	start := strings.Index(src[i:], "{")
+	end := strings.Index(src[i:], "};")
+	if start < 0 || end < start {
+		return nil, false
+	}
+	var values []int
+	for _, tok := range numberRe.FindAllString(src[i+start:i+end], -1) {
+		v, err := strconv.ParseInt(tok, 0, 64)
+		if err != nil {
+			return nil, false
+		}
+		values = append(values, int(v))
+	}
+	return values, true
+}
+
+// glyphIndex returns the offset, the scheme and the first stored row of
+// every glyph of an index in src, or false if there is none. Delta-coded
+// offsets are summed up, tops is nil unless rows are trimmed.
+func glyphIndex(src string, n int) (offsets, schemes, tops []int, ok bool) {
+	var fields []string
+	if m := fieldsRe.FindStringSubmatch(src); m != nil {
+		for _, f := range fieldRe.FindAllStringSubmatch(m[1], -1) {
+			fields = append(fields, f[1])
+		}
+	}
+	column := func(name string) ([]int, bool) {
+		if values, ok := arrayValues(src, "FontCustom_Glyph"+strings.ToUpper(name[:1])+name[1:]); ok {
+			return values, true
+		}
+		values, ok := arrayValues(src, "FontCustom_Index")
+		for i, f := range fields {
+			if ok && f == name && len(values) == n*len(fields) {
+				col := make([]int, n)
+				for j := range col {
+					col[j] = values[j*len(fields)+i]
+				}
+				return col, true
+			}
+		}
+		return nil, false
+	}
+	if offsets, ok = column("offset"); !ok || len(offsets) != n {
+		return nil, nil, nil, false
+	}
+	if strings.Contains(src, "FontCustom_IndexOffset(") {
+		for i := 1; i < n; i++ {
+			offsets[i] += offsets[i-1]
+		}
+	}
+	if schemes, ok = column("scheme"); !ok {
+		schemes = make([]int, n)
+	}
+	tops, _ = column("yOffset")
+	return offsets, schemes, tops, true
+}
+
+// readHeader parses a header generated in the c format and decodes its
+// glyphs.
+func readHeader(name string) (*header, error) {
+	b, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	raw := string(b)
+	src := commentRe.ReplaceAllString(raw, "")
+	h := &header{name: name, glyphs: map[rune]glyph{}}
+	m := structRe.FindStringSubmatch(src)
+	if m == nil {
+		return nil, fmt.Errorf("%s: no sFONT FontCustom struct", name)
+	}
+	h.width, _ = strconv.Atoi(m[1])
+	h.height, _ = strconv.Atoi(m[2])
+	table, ok := arrayValues(src, "FontCustom_Table")
+	if !ok {
+		return nil, fmt.Errorf("%s: no FontCustom_Table", name)
+	}
+	proto := glyph{width: h.width, height: h.height, bpp: 1}
+	if m := bppRe.FindStringSubmatch(raw); m != nil {
+		proto.bpp, _ = strconv.Atoi(m[1])
+		proto.lowNibbleFirst = m[2] == "low" // headers without FontCustom_LowNibbleFirst
+	}
+	if m := nibbleRe.FindStringSubmatch(raw); m != nil {
+		proto.lowNibbleFirst = m[1] == "1"
+	}
+	runes := asciiRunes()
+	if cps, ok := arrayValues(src, "FontCustom_Codepoints"); ok {
+		runes = runes[:0]
+		for _, v := range cps {
+			runes = append(runes, rune(v))
+		}
+	}
+	size := proto.rowBytes() * h.height
+	stride := size
+	if m := strideRe.FindStringSubmatch(src); m != nil {
+		stride, _ = strconv.Atoi(m[1])
+	}
+	offsets, schemes, tops, indexed := glyphIndex(src, len(runes))
+	phases := 1
+	if m := phasesRe.FindStringSubmatch(src); m != nil {
+		phases, _ = strconv.Atoi(m[1])
+	}
+	remap, remapped := arrayValues(src, "FontCustom_Remap")
+	if remapped && len(remap) != len(runes) {
+		return nil, fmt.Errorf("%s: FontCustom_Remap has %d slots for %d glyphs", name, len(remap), len(runes))
+	}
+	ragged := indexed && strings.Contains(src, "FontCustom_DecodeRagged(")
+	rowMask := indexed && strings.Contains(src, "FontCustom_DecodeRows(")
+	for i, v := range runes {
+		g := proto
+		g.rune = v
+		slot := i * phases // phase 0
+		if remapped {
+			slot = remap[i]
+		}
+		start, end := slot*stride, slot*stride+size
+		if indexed {
+			start, end = offsets[i], len(table)
+			if i+1 < len(runes) {
+				end = offsets[i+1]
+			}
+		}
+		if start > end || end > len(table) {
+			return nil, fmt.Errorf("%s: the table ends in the glyph of U+%04X", name, v)
+		}
+		for _, o := range table[start:end] {
+			g.data = append(g.data, byte(o))
+		}
+		switch {
+		case ragged:
+			g.data, err = decodeRagged(g.data, g.width, g.height)
+		case rowMask:
+			g.data, err = decodeRowMask(g.data, g.rowBytes(), g.height)
+		case tops != nil:
+			g.data = append(make([]byte, tops[i]*g.rowBytes()), g.data...)
+			g.data = append(g.data, make([]byte, max(size-len(g.data), 0))...)
+		case indexed && schemes[i] == schemeRLE:
+			g.data, err = decodeRLE(g.data)
+		case indexed && schemes[i] == schemeBitRLE:
+			g.data, err = decodeBitRLE(g.data)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: U+%04X: %v", name, v, err)
+		}
+		if indexed && len(g.data) > size {
+			// the last glyph is followed by a sentinel or padding
+			g.data = g.data[:size]
+		}
+		if len(g.data) != size {
+			return nil, fmt.Errorf("%s: the glyph of U+%04X has %d bytes instead of %d", name, v, len(g.data), size)
+		}
+		h.glyphs[v] = g
+	}
+	return h, nil
+}
+
+// levelAt returns the level of the pixel at x,y of g, 0 outside of it.
+func levelAt(g *glyph, x, y int) int {
+	if x >= g.width || y >= g.height {
+		return 0
+	}
+	return g.level(x, y)
+}
+
+// glyphDiff returns the number of pixels in which a and b differ.
+func glyphDiff(a, b *glyph) int {
+	n := 0
+	for y := 0; y < max(a.height, b.height); y++ {
+		for x := 0; x < max(a.width, b.width); x++ {
+			if levelAt(a, x, y) != levelAt(b, x, y) {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// artRow returns row y of g as ASCII art of the given width.
+func artRow(g *glyph, y, width int) string {
+	const digits = ".123456789ABCDEF"
+	var s strings.Builder
+	for x := 0; x < width; x++ {
+		l := levelAt(g, x, y)
+		if g.bpp == 1 && l != 0 {
+			s.WriteByte('#')
+		} else {
+			s.WriteByte(digits[l])
+		}
+	}
+	return s.String()
+}
+
+// headerRunes returns the sorted codepoints of the glyphs of a and b.
+func headerRunes(a, b *header) []rune {
+	var runes []rune
+	for v := range a.glyphs {
+		runes = append(runes, v)
+	}
+	for v := range b.glyphs {
+		if _, ok := a.glyphs[v]; !ok {
+			runes = append(runes, v)
+		}
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes
+}
+
+// compareHeaders writes the codepoints whose glyphs differ between the
+// headers a and b to w, with art side by side. It returns the number of
+// differing glyphs.
+func compareHeaders(w io.Writer, a, b *header, art bool) int {
+	if a.width != b.width || a.height != b.height {
+		fmt.Fprintf(w, "cell %dx%d in %s, %dx%d in %s\n", a.width, a.height, a.name, b.width, b.height, b.name)
+	}
+	runes := headerRunes(a, b)
+	changed := 0
+	for _, v := range runes {
+		ga, inA := a.glyphs[v]
+		gb, inB := b.glyphs[v]
+		switch {
+		case !inB:
+			fmt.Fprintf(w, "U+%04X %s only in %s\n", v, printable(v), a.name)
+		case !inA:
+			fmt.Fprintf(w, "U+%04X %s only in %s\n", v, printable(v), b.name)
+		default:
+			n := glyphDiff(&ga, &gb)
+			if n == 0 {
+				continue
+			}
+			fmt.Fprintf(w, "U+%04X %s differs in %d pixels\n", v, printable(v), n)
+			if art {
+				width := max(ga.width, gb.width)
+				for y := 0; y < max(ga.height, gb.height); y++ {
+					ra, rb := artRow(&ga, y, width), artRow(&gb, y, width)
+					mark := " "
+					if ra != rb {
+						mark = "|"
+					}
+					fmt.Fprintf(w, "  %s %s %s\n", ra, mark, rb)
+				}
+			}
+		}
+		changed++
+	}
+	fmt.Fprintf(w, "%d of %d glyphs differ\n", changed, len(runes))
+	return changed
+}
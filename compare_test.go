@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// TestCompareRoundTrip writes 4 bpp headers without comments in both
+// nibble orders, reads them back and compares the glyphs with the render.
+func TestCompareRoundTrip(t *testing.T) {
+	for _, order := range []string{"high", "low"} {
+		t.Run(order, func(t *testing.T) {
+			f, opts := testSetup(t)
+			opts.Bpp, opts.NibbleOrder, opts.CommentStyle = 4, order, "none"
+			glyphs, err := renderGlyphs(f, asciiRunes(), opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			name := filepath.Join(t.TempDir(), "font.c")
+			var b bytes.Buffer
+			if err := write(&b, glyphs, opts); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(name, b.Bytes(), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			h, err := readHeader(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, g := range glyphs {
+				got := h.glyphs[g.rune]
+				if got.bpp != 4 || got.lowNibbleFirst != (order == "low") {
+					t.Fatalf("U+%04X read as %d bpp, low nibble first %v", g.rune, got.bpp, got.lowNibbleFirst)
+				}
+				if n := glyphDiff(&g, &got); n != 0 {
+					t.Fatalf("U+%04X differs in %d pixels", g.rune, n)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+)
+
+// flagGiven reports by name whether a flag is given, for the rules of
+// flagRules.
+var flagGiven = map[string]func(o *options) bool{
+	"align":                  func(o *options) bool { return o.Align != 0 },
+	"align-glyph":            func(o *options) bool { return o.AlignGlyph != "" },
+	"always-index":           func(o *options) bool { return o.AlwaysIndex },
+	"auto-compress":          func(o *options) bool { return o.AutoCompress },
+	"bin-indexed":            func(o *options) bool { return o.BinIndexed },
+	"compare-strikes":        func(o *options) bool { return o.CompareStrikes },
+	"constexpr":              func(o *options) bool { return o.Constexpr },
+	"contiguous-glyphs":      func(o *options) bool { return o.ContiguousGlyphs },
+	"embed-render-params":    func(o *options) bool { return o.EmbedRenderParams },
+	"emit-test":              func(o *options) bool { return o.EmitTest != "" },
+	"fixed-width-var-height": func(o *options) bool { return o.FixedWidthVarHeight },
+	"grid-strict":            func(o *options) bool { return o.GridStrict },
+	"layout":                 func(o *options) bool { return o.Layout != "" },
+	"manifest":               func(o *options) bool { return o.Manifest != "" },
+	"order-for-compression":  func(o *options) bool { return o.OrderForCompression },
+	"overshoot-trim":         func(o *options) bool { return o.OvershootTrim > 0 },
+	"pad-pow2":               func(o *options) bool { return o.PadPow2 },
+	"per-glyph-crc":          func(o *options) bool { return o.PerGlyphCRC != "" },
+	"phf":                    func(o *options) bool { return o.PHF },
+	"ragged-rows":            func(o *options) bool { return o.RaggedRows },
+	"respect-bearings":       func(o *options) bool { return o.RespectBearings },
+	"right origin":           func(o *options) bool { return o.origin.x == "right" },
+	"rotate-map":             func(o *options) bool { return o.RotateMap != "" },
+	"sentinel":               func(o *options) bool { return o.Sentinel != "" },
+	"separate-glyphs":        func(o *options) bool { return o.SeparateGlyphs },
+	"skip-empty-rows":        func(o *options) bool { return o.SkipEmptyRows },
+	"snap-origin":            func(o *options) bool { return o.SnapOrigin },
+	"subpixel-phases":        func(o *options) bool { return o.SubpixelPhases > 1 },
+	"tabular-figures":        func(o *options) bool { return o.TabularFigures },
+	"two-plane":              func(o *options) bool { return o.TwoPlane },
+	"vmetric-align":          func(o *options) bool { return o.VMetricAlign },
+}
+
+// flagRule restricts a flag to the formats supporting it and excludes the
+// flags it cannot be combined with.
+type flagRule struct {
+	flag     string
+	formats  []string // any format if empty
+	excludes []string
+}
+
+// flagRules lists the restrictions of the flags. An exclusion applies both
+// ways, so it is listed with one of the two flags.
+var flagRules = []flagRule{
+	{"sentinel", []string{"c", "cpp"}, nil},
+	{"align", []string{"c", "cpp", "strip"}, nil},
+	{"pad-pow2", []string{"c", "cpp"}, []string{"auto-compress", "ragged-rows"}},
+	{"embed-render-params", []string{"c", "freetype", "strip"}, nil},
+	{"constexpr", []string{"cpp"}, nil},
+	{"bin-indexed", []string{"bin"}, nil},
+	{"auto-compress", []string{"c"}, nil},
+	{"separate-glyphs", []string{"c"}, []string{"auto-compress", "sentinel", "contiguous-glyphs"}},
+	{"contiguous-glyphs", []string{"c"}, nil},
+	{"layout", []string{"c"}, []string{"separate-glyphs"}},
+	{"two-plane", []string{"c"}, []string{"auto-compress", "separate-glyphs"}},
+	{"ragged-rows", []string{"c"}, []string{"auto-compress", "two-plane", "separate-glyphs"}},
+	{"per-glyph-crc", []string{"c"}, []string{"two-plane"}},
+	{"phf", []string{"c"}, nil},
+	{"order-for-compression", []string{"c"}, []string{"auto-compress", "layout", "ragged-rows", "separate-glyphs", "contiguous-glyphs", "two-plane"}},
+	{"always-index", []string{"c"}, []string{"separate-glyphs", "order-for-compression"}},
+	{"emit-test", []string{"c"}, []string{"auto-compress", "layout", "ragged-rows", "separate-glyphs", "contiguous-glyphs", "two-plane"}},
+	{"grid-strict", []string{"c", "cpp", "freetype", "bin"}, []string{"respect-bearings", "vmetric-align", "right origin", "align-glyph",
+		"tabular-figures", "overshoot-trim", "layout", "auto-compress", "ragged-rows"}},
+	{"rotate-map", []string{"c"}, []string{"pad-pow2", "ragged-rows", "two-plane", "separate-glyphs", "order-for-compression", "emit-test", "grid-strict"}},
+	{"skip-empty-rows", []string{"c"}, []string{"auto-compress", "ragged-rows", "two-plane", "separate-glyphs", "pad-pow2", "rotate-map",
+		"order-for-compression", "grid-strict", "emit-test"}},
+	{"subpixel-phases", []string{"c"}, []string{"auto-compress", "layout", "ragged-rows", "skip-empty-rows", "separate-glyphs", "two-plane",
+		"contiguous-glyphs", "order-for-compression", "phf", "manifest", "emit-test", "per-glyph-crc", "rotate-map", "always-index",
+		"align-glyph", "snap-origin"}},
+	{"fixed-width-var-height", []string{"c"}, []string{"auto-compress", "ragged-rows", "skip-empty-rows", "two-plane", "separate-glyphs",
+		"pad-pow2", "rotate-map", "order-for-compression", "grid-strict", "emit-test", "subpixel-phases"}},
+	{"compare-strikes", nil, []string{"rotate-map", "subpixel-phases"}},
+}
+
+// checkFlags returns an error for the first given flag of flagRules which
+// the format does not support or which is combined with a flag it excludes.
+func checkFlags(o *options) error {
+	for _, r := range flagRules {
+		if !flagGiven[r.flag](o) {
+			continue
+		}
+		if len(r.formats) > 0 && !slices.Contains(r.formats, o.Format) {
+			return fmt.Errorf("%s is not supported by the %s format", r.flag, o.Format)
+		}
+		for _, x := range r.excludes {
+			if flagGiven[x](o) {
+				return fmt.Errorf("%s and %s are mutually exclusive", r.flag, x)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package main
+
+import "testing"
+
+// TestFlagRules checks that every flag named in flagRules can be tested.
+func TestFlagRules(t *testing.T) {
+	for _, r := range flagRules {
+		for _, name := range append([]string{r.flag}, r.excludes...) {
+			if flagGiven[name] == nil {
+				t.Errorf("rule of %s: unknown flag %s", r.flag, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// Schemes of a stored glyph as recorded in the index.
+const (
+	schemeRaw = 0 // the packed rows as is
+	schemeRLE = 1 // pairs of a run length (1 to 255) and a byte value
+	// schemeRagged is the used bit count of every row followed by its
+	// bits. It is only used for all glyphs with ragged-rows.
+	schemeRagged = 2
+	// schemeBitRLE is bytes of a pixel value in bit 7 and a run length
+	// minus 1 (1 to 128 bits) in bits 0 to 6, over the bits of the rows.
+	schemeBitRLE = 3
+	// schemeRowMask is a mask of the stored rows followed by the rows with
+	// ink. It is only used for all glyphs with skip-empty-rows.
+	schemeRowMask = 4
+	// schemeTrimmed is the rows from the first to the last row with ink.
+	// It is only used for all glyphs with fixed-width-var-height.
+	schemeTrimmed = 5
+)
+
+var schemeNames = map[byte]string{
+	schemeRaw:     "raw",
+	schemeRLE:     "RLE",
+	schemeRagged:  "ragged",
+	schemeBitRLE:  "bit RLE",
+	schemeRowMask: "row mask",
+	schemeTrimmed: "trimmed",
+}
+
+// encodeRLE run-length encodes data as pairs of count and value.
+func encodeRLE(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); {
+		n := 1
+		for i+n < len(data) && n < 255 && data[i+n] == data[i] {
+			n++
+		}
+		out = append(out, byte(n), data[i])
+		i += n
+	}
+	return out
+}
+
+// decodeRLE decodes pairs of count and value written by encodeRLE.
+func decodeRLE(data []byte) ([]byte, error) {
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("RLE data of odd length %d", len(data))
+	}
+	var out []byte
+	for i := 0; i < len(data); i += 2 {
+		for n := 0; n < int(data[i]); n++ {
+			out = append(out, data[i+1])
+		}
+	}
+	return out, nil
+}
+
+// encodeBitRLE run-length encodes the bits of data, MSB first, as bytes
+// of the bit value in bit 7 and the run length minus 1 in bits 0 to 6.
+func encodeBitRLE(data []byte) []byte {
+	bit := func(i int) byte { return data[i/8] >> (7 - i%8) & 1 }
+	var out []byte
+	for i, n := 0, len(data)*8; i < n; {
+		run := 1
+		for i+run < n && run < 128 && bit(i+run) == bit(i) {
+			run++
+		}
+		out = append(out, bit(i)<<7|byte(run-1))
+		i += run
+	}
+	return out
+}
+
+// decodeBitRLE decodes runs written by encodeBitRLE.
+func decodeBitRLE(data []byte) ([]byte, error) {
+	var out []byte
+	n := 0
+	for _, b := range data {
+		for run := int(b&0x7F) + 1; run > 0; run-- {
+			if n%8 == 0 {
+				out = append(out, 0)
+			}
+			out[n/8] |= b >> 7 << (7 - n%8)
+			n++
+		}
+	}
+	if n%8 != 0 {
+		return nil, fmt.Errorf("bit RLE data of %d bits, not whole bytes", n)
+	}
+	return out, nil
+}
+
+// rleScheme returns the RLE scheme of unit and its encoder.
+func rleScheme(unit string) (byte, func([]byte) []byte) {
+	if unit == "bit" {
+		return schemeBitRLE, encodeBitRLE
+	}
+	return schemeRLE, encodeRLE
+}
+
+// autoCompress stores every glyph with the smallest of raw and the RLE of
+// unit.
+func autoCompress(glyphs []glyph, unit string) {
+	scheme, encode := rleScheme(unit)
+	for i := range glyphs {
+		g := &glyphs[i]
+		if rle := encode(g.data); len(rle) < len(g.data) {
+			g.stored, g.scheme = rle, scheme
+		}
+	}
+}
+
+// writeCompressionSummary writes the size of the table with every scheme
+// and with the per-glyph choice to w.
+func writeCompressionSummary(w io.Writer, glyphs []glyph, unit string) {
+	_, encode := rleScheme(unit)
+	raw, rle, auto := 0, 0, 0
+	for _, g := range glyphs {
+		raw += len(g.data)
+		rle += len(encode(g.data))
+		auto += len(g.storedData())
+	}
+	fmt.Fprintf(w, "auto-compress: %d bytes, raw %d bytes (saved %d), RLE %d bytes (saved %d)\n",
+		auto, raw, raw-auto, rle, rle-auto)
+}
+
+// writeRLEDecoder writes the C decoder of the RLE of unit.
+func writeRLEDecoder(out io.Writer, unit string, opts *options) {
+	fmt.Fprint(out, "\n\n")
+	writePgmRead(out, "uint8_t")
+	fmt.Fprintln(out)
+	if unit == "bit" {
+		if c := opts.blockComment("FontCustom_DecodeBitRLE unpacks a glyph of scheme 3 starting at src into n bytes at dst: every byte is a pixel value in bit 7 and a run length minus 1 in bits 0 to 6"); c != "" {
+			fmt.Fprintln(out, c)
+		}
+		fmt.Fprint(out, `static inline void FontCustom_DecodeBitRLE(const uint8_t *src, uint8_t *dst, uint16_t n)
+{
+  for (uint16_t i = 0; i < n; i++)
+    dst[i] = 0;
+  for (uint32_t bit = 0; bit < (uint32_t)n * 8; src++)
+  {
+    uint8_t b = pgm_read_byte(src);
+    for (uint8_t run = (b & 0x7F) + 1; run > 0; run--, bit++)
+      if (b & 0x80)
+        dst[bit >> 3] |= 0x80 >> (bit & 7);
+  }
+}`)
+		return
+	}
+	if c := opts.blockComment("FontCustom_DecodeRLE unpacks a glyph of scheme 1 starting at src into n bytes at dst: pairs of a run length and the byte repeated"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprint(out, `static inline void FontCustom_DecodeRLE(const uint8_t *src, uint8_t *dst, uint16_t n)
+{
+  for (uint16_t i = 0; i < n; src += 2)
+    for (uint8_t run = pgm_read_byte(&src[0]); run > 0; run--)
+      dst[i++] = pgm_read_byte(&src[1]);
+}`)
+}
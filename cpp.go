@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// writeCpp writes the glyphs as a header-only C++ class to w.
+func writeCpp(w io.Writer, glyphs []glyph, opts *options) error {
+	out := bufio.NewWriter(w)
+	width, height := opts.storedCell()
+	runes := glyphRunes(glyphs)
+	glyphBytes := 0
+	if len(glyphs) > 0 {
+		glyphBytes = len(glyphs[0].data)
+	}
+
+	fmt.Fprint(out, `#pragma once
+
+#include <stddef.h>
+#include <stdint.h>
+#if defined(__AVR__) || defined(ARDUINO_ARCH_SAMD)
+#include <avr/pgmspace.h>
+#elif defined(ESP8266) || defined(ESP32)
+#include <pgmspace.h>
+#else
+#define PROGMEM
+#endif
+#ifndef pgm_read_dword
+#define pgm_read_dword(addr) (*(const uint32_t *)(addr))
+#endif
+
+`)
+	if c := opts.blockComment("Based on font " + string(opts.Font)); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, `class FontCustom
+{
+public:
+  static constexpr uint16_t width() { return %d; }
+  static constexpr uint16_t height() { return %d; }
+`, width, height)
+	if opts.Bpp != 1 {
+		fmt.Fprintf(out, "  static constexpr uint8_t bpp() { return %d; }%s\n", opts.Bpp, opts.trailingComment(opts.NibbleOrder+" nibble first"))
+		fmt.Fprintf(out, "  static constexpr bool lowNibbleFirst() { return %t; }\n", opts.NibbleOrder == "low")
+	}
+	if len(opts.sentinel) > 0 {
+		fmt.Fprintf(out, "  static constexpr size_t sentinelSize() { return %d; }%s\n", len(opts.sentinel), opts.sentinelComment())
+	}
+	fmt.Fprintln(out)
+	if c := opts.blockComment("glyph returns the bitmap of c, or nullptr if the font has no glyph for it"); c != "" {
+		fmt.Fprintf(out, "  %s\n", c)
+	}
+	// constexpr data is read directly, even at run time, so it is not
+	// placed in program memory
+	progmem, decl, read := " PROGMEM", "static const uint8_t *glyph(uint32_t c)", "pgm_read_dword(&codepoints_[mid])"
+	if opts.Constexpr {
+		progmem, decl, read = "", "static constexpr const uint8_t *glyph(uint32_t c)", "codepoints_[mid]"
+	}
+	fmt.Fprintf(out, "  %s\n  {\n", decl)
+	if contiguousASCII(runes) {
+		fmt.Fprintf(out, `    if (c < 0x%.4X || c > 0x%.4X)
+      return nullptr;
+    return &table_[(c - 0x%.4X) * %d];
+`, runes[0], runes[len(runes)-1], runes[0], glyphBytes)
+	} else {
+		fmt.Fprintf(out, `    size_t lo = 0, hi = %d;
+    while (lo < hi)
+    {
+      size_t mid = (lo + hi) / 2;
+      uint32_t m = %s;
+      if (m == c)
+        return &table_[mid * %d];
+      if (m < c)
+        lo = mid + 1;
+      else
+        hi = mid;
+    }
+    return nullptr;
+`, len(runes), read, glyphBytes)
+	}
+	fmt.Fprintln(out, "  }\n\nprivate:")
+	fmt.Fprintf(out, "  static %s uint8_t table_[]%s%s =\n  {\n", opts.cppConst(), progmem, opts.alignAttr())
+	writeGlyphData(out, glyphs, opts, "    ")
+	fmt.Fprintln(out, "  };")
+	if !contiguousASCII(runes) {
+		fmt.Fprintf(out, "  static %s uint32_t codepoints_[]%s =\n  {\n", opts.cppConst(), progmem)
+		for i, v := range runes {
+			if i%8 == 0 {
+				if i > 0 {
+					fmt.Fprintln(out)
+				}
+				fmt.Fprint(out, "   ")
+			}
+			fmt.Fprintf(out, " 0x%.4X,", v)
+		}
+		fmt.Fprintln(out, "\n  };")
+	}
+	fmt.Fprintln(out, "};")
+	return out.Flush()
+}
+
+// cppConst returns the qualifiers of the static data members.
+func (o *options) cppConst() string {
+	if o.Constexpr {
+		return "constexpr"
+	}
+	return "inline const"
+}
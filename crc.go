@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// crc8 returns the CRC-8/SMBUS of data: polynomial 0x07, initial value 0,
+// not reflected, no final xor.
+func crc8(data []byte) uint8 {
+	var crc uint8
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x07
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// crc16 returns the CRC-16/CCITT-FALSE of data: polynomial 0x1021, initial
+// value 0xFFFF, not reflected, no final xor.
+func crc16(data []byte) uint16 {
+	crc := uint16(0xFFFF)
+	for _, b := range data {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+// writeGlyphCRCs writes the CRC of the stored bytes of every glyph.
+func writeGlyphCRCs(out io.Writer, glyphs []glyph, opts *options) {
+	typ, doc := "uint8_t", "CRC-8/SMBUS: polynomial 0x07, initial value 0x00, not reflected, no final xor"
+	if opts.PerGlyphCRC == "16" {
+		typ, doc = "uint16_t", "CRC-16/CCITT-FALSE: polynomial 0x1021, initial value 0xFFFF, not reflected, no final xor"
+	}
+	fmt.Fprint(out, "\n\n")
+	if c := opts.blockComment(doc + ", of the stored bytes of every glyph"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, "const %s FontCustom_GlyphCrc [] PROGMEM =\n{\n", typ)
+	for i, g := range glyphs {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		if opts.PerGlyphCRC == "16" {
+			fmt.Fprintf(out, " 0x%.4X,", crc16(g.storedData()))
+		} else {
+			fmt.Fprintf(out, " 0x%.2X,", crc8(g.storedData()))
+		}
+	}
+	fmt.Fprint(out, "\n};")
+}
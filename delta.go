@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// deltas returns the difference of every offset to the previous one, the
+// first offset is kept as is.
+func deltas(offsets []int) []int {
+	d := make([]int, len(offsets))
+	prev := 0
+	for i, o := range offsets {
+		d[i], prev = o-prev, o
+	}
+	return d
+}
+
+// uintType returns the smallest unsigned C type holding all values.
+func uintType(values []int) string {
+	typ := "uint8_t"
+	for _, v := range values {
+		switch {
+		case v > 0xFFFF:
+			return "uint32_t"
+		case v > 0xFF:
+			typ = "uint16_t"
+		}
+	}
+	return typ
+}
+
+// pgmRead returns the pgm_read macro for the unsigned C type typ.
+func pgmRead(typ string) string {
+	switch typ {
+	case "uint8_t":
+		return "pgm_read_byte"
+	case "uint16_t":
+		return "pgm_read_word"
+	}
+	return "pgm_read_dword"
+}
+
+// writePgmRead writes a fallback for the pgm_read macro of typ for
+// platforms without pgmspace.h.
+func writePgmRead(out io.Writer, typ string) {
+	fmt.Fprintf(out, "#ifndef %[1]s\n#define %[1]s(addr) (*(const %[2]s *)(addr))\n#endif\n", pgmRead(typ), typ)
+}
+
+// writeDeltaDecoder writes the C function name returning the offset of
+// glyph i as the prefix sum of the deltas element, which is an expression
+// of the index j.
+func writeDeltaDecoder(out io.Writer, name, element, typ string, opts *options) {
+	fmt.Fprint(out, "\n\n")
+	writePgmRead(out, typ)
+	fmt.Fprintln(out)
+	if c := opts.blockComment(name + " returns the offset of glyph i as the sum of the deltas up to i"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, `static inline uint32_t %s(uint16_t i)
+{
+  uint32_t offset = 0;
+  for (uint16_t j = 0; j <= i; j++)
+    offset += %s(&%s);
+  return offset;
+}`, name, pgmRead(typ), element)
+}
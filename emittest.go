@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// writeTestStub writes a host C program which includes the generated font,
+// prints every glyph as ASCII art read from FontCustom.table and compares
+// it with the art of the generator. It exits with 1 on any difference.
+func writeTestStub(w io.Writer, glyphs []glyph, opts *options) error {
+	out := bufio.NewWriter(w)
+	width, height := opts.storedCell()
+	stride := (width*opts.Bpp + 7) / 8 * height
+	if opts.PadPow2 {
+		stride = glyphStride(opts)
+	}
+	if c := opts.blockComment("Test stub for the font generated from " + string(opts.Font) + ", build and run it on the host"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, "#include <stdio.h>\n#include <string.h>\n#include %s\n\n", strconv.Quote(opts.EmitTestInclude))
+	fmt.Fprintf(out, "static const uint32_t codepoints[] = {")
+	for i, g := range glyphs {
+		if i%8 == 0 {
+			fmt.Fprint(out, "\n ")
+		}
+		fmt.Fprintf(out, " 0x%.4X,", g.rune)
+	}
+	fmt.Fprint(out, "\n};\n\n")
+	fmt.Fprintf(out, "static const uint32_t slots[] = {")
+	for i := range glyphs {
+		if i%16 == 0 {
+			fmt.Fprint(out, "\n ")
+		}
+		slot := i
+		if opts.order != nil {
+			slot = slotOf(opts.order, i)
+		}
+		fmt.Fprintf(out, " %d,", slot)
+	}
+	fmt.Fprint(out, "\n};\n\nstatic const char *const expected[] = {\n")
+	for _, g := range glyphs {
+		for _, row := range g.art {
+			fmt.Fprintf(out, "  \"%s\",\n", row)
+		}
+	}
+	fmt.Fprint(out, "};\n\n")
+	rowBytes := (width*opts.Bpp + 7) / 8
+	level := fmt.Sprintf("(g[y * %d + x / 8] >> (7 - x %% 8)) & 1", rowBytes)
+	if opts.Bpp == 4 {
+		first := "x % 2 == 0" // the high nibble holds the even pixel
+		if opts.NibbleOrder == "low" {
+			first = "x % 2 == 1"
+		}
+		level = fmt.Sprintf("(g[y * %d + x / 2] >> (%s ? 4 : 0)) & 15", rowBytes, first)
+	}
+	fmt.Fprintf(out, `int main(void)
+{
+  const char *digits = %q;
+  int failed = 0;
+  char row[%d];
+  if (FontCustom.Width != %d || FontCustom.Height != %d)
+  {
+    printf("cell %%ux%%u, want %dx%d\n", FontCustom.Width, FontCustom.Height);
+    return 1;
+  }
+  for (uint32_t i = 0; i < %d; i++)
+  {
+    const uint8_t *g = &FontCustom.table[slots[i] * %d];
+    printf("U+%%04lX\n", (unsigned long)codepoints[i]);
+    for (uint32_t y = 0; y < %d; y++)
+    {
+      for (uint32_t x = 0; x < %d; x++)
+        row[x] = digits[%s];
+      row[%d] = 0;
+      int bad = strcmp(row, expected[i * %d + y]) != 0;
+      printf("  %%s%%s\n", row, bad ? "  <- differs" : "");
+      failed += bad;
+    }
+  }
+  printf("%%d rows differ\n", failed);
+  return failed != 0;
+}
+`, stubDigits(opts.Bpp), width+1, width, height, width, height, len(glyphs), stride, height, width, level, width, height)
+	return out.Flush()
+}
+
+// stubDigits returns the art characters of the levels at bpp.
+func stubDigits(bpp int) string {
+	if bpp == 4 {
+		return ".123456789ABCDEF"
+	}
+	return ".#"
+}
+
+// slotOf returns the slot of glyph i in the table ordered by order.
+func slotOf(order []int, i int) int {
+	for slot, j := range order {
+		if j == i {
+			return slot
+		}
+	}
+	return -1
+}
+
+// writeTestFile writes the test stub to the emit-test file.
+func writeTestFile(glyphs []glyph, opts *options) error {
+	file, err := os.Create(string(opts.EmitTest))
+	if err != nil {
+		return err
+	}
+	if err := writeTestStub(file, glyphs, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// loadFallbacks parses the fallback fonts.
+func loadFallbacks(opts *options) ([]*sfnt.Font, error) {
+	var fonts []*sfnt.Font
+	for _, name := range opts.Fallback {
+		b, err := os.ReadFile(string(name))
+		if err != nil {
+			return nil, err
+		}
+		f, err := sfnt.Parse(b)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %s: %v", name, err)
+		}
+		fonts = append(fonts, f)
+	}
+	return fonts, nil
+}
+
+// hasGlyph reports whether f has a glyph for v.
+func hasGlyph(f *sfnt.Font, buf *sfnt.Buffer, v rune) (bool, error) {
+	x, err := f.GlyphIndex(buf, v)
+	if err != nil {
+		return false, fmt.Errorf("GlyphIndex: %v", err)
+	}
+	return x != 0, nil
+}
+
+// glyphSource returns the index of the first renderer whose font has a
+// glyph for v. It is 0 if none has, so the primary font reports the rune.
+func glyphSource(renderers []*renderer, v rune) (int, error) {
+	for i, r := range renderers {
+		ok, err := hasGlyph(r.font, &r.buf, v)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			return i, nil
+		}
+	}
+	return 0, nil
+}
+
+// sourceRange is a run of consecutive codepoints rendered from the same
+// font, stored from index on in the table.
+type sourceRange struct {
+	First  rune
+	Last   rune
+	Source int // 0 is the primary font, i the fallback i
+	Index  int
+}
+
+// sourceRanges returns the ranges of the glyphs by source font.
+func sourceRanges(glyphs []glyph) []sourceRange {
+	var ranges []sourceRange
+	for i, g := range glyphs {
+		if n := len(ranges); n > 0 && ranges[n-1].Source == g.source && ranges[n-1].Last+1 == g.rune {
+			ranges[n-1].Last = g.rune
+			continue
+		}
+		ranges = append(ranges, sourceRange{First: g.rune, Last: g.rune, Source: g.source, Index: i})
+	}
+	return ranges
+}
+
+// sourceNames returns the file names of the primary and the fallback fonts.
+func (o *options) sourceNames() []string {
+	names := []string{string(o.Font)}
+	for _, name := range o.Fallback {
+		names = append(names, string(name))
+	}
+	return names
+}
+
+// writeManifestC writes the source ranges of the glyphs as a C table.
+func writeManifestC(out io.Writer, glyphs []glyph, opts *options) {
+	fmt.Fprintf(out, `
+
+typedef struct {
+  uint32_t first;
+  uint32_t last;
+  uint16_t index;%s
+  uint8_t source;%s
+} FontCustom_SourceRange;
+
+`, opts.trailingComment("of the glyph of first"), opts.trailingComment("0: primary font, i: fallback i"))
+	for i, name := range opts.sourceNames() {
+		if c := opts.comment(fmt.Sprintf("source %d: %s", i, name)); c != "" {
+			fmt.Fprintln(out, c)
+		}
+	}
+	fmt.Fprint(out, "const FontCustom_SourceRange FontCustom_Sources [] PROGMEM =\n{\n")
+	ranges := sourceRanges(glyphs)
+	for _, r := range ranges {
+		fmt.Fprintf(out, "  {0x%.4X, 0x%.4X, %d, %d},\n", r.First, r.Last, r.Index, r.Source)
+	}
+	fmt.Fprintf(out, "};\n\nconst uint16_t FontCustom_SourceCount = %d;", len(ranges))
+}
+
+// writeManifestJSON writes the source fonts and the source ranges of the
+// glyphs as JSON to w.
+func writeManifestJSON(w io.Writer, glyphs []glyph, opts *options) error {
+	type jsonRange struct {
+		First  string `json:"first"`
+		Last   string `json:"last"`
+		Source int    `json:"source"`
+		Index  int    `json:"index"`
+	}
+	m := struct {
+		Sources []string    `json:"sources"`
+		Ranges  []jsonRange `json:"ranges"`
+	}{Sources: opts.sourceNames()}
+	for _, r := range sourceRanges(glyphs) {
+		m.Ranges = append(m.Ranges, jsonRange{fmt.Sprintf("U+%04X", r.First), fmt.Sprintf("U+%04X", r.Last), r.Source, r.Index})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(m)
+}
+
+// writeManifestFile writes the JSON manifest to the configured file.
+func writeManifestFile(glyphs []glyph, opts *options) error {
+	file, err := os.Create(string(opts.ManifestFile))
+	if err != nil {
+		return err
+	}
+	if err := writeManifestJSON(file, glyphs, opts); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
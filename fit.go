@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// searchPPEM returns the largest PPEM from 1 to max for which ok holds.
+// ok must hold for all sizes below one it holds for. It returns 0 if ok
+// does not even hold for 1.
+func searchPPEM(max int, ok func(ppem int) (bool, error)) (int, error) {
+	lo, hi := 0, max
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		good, err := ok(mid)
+		if err != nil {
+			return 0, err
+		}
+		if good {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// fits reports whether the runes of f fit into the cell at ppem: the
+// ascent above and the descent below the baseline, and the advance of
+// every rune right of the x offset.
+func fits(f *sfnt.Font, runes []rune, opts *options, ppem int) (bool, error) {
+	var buf sfnt.Buffer
+	m, err := f.Metrics(&buf, fixed.I(ppem), font.HintingFull)
+	if err != nil {
+		return false, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	ascent, descent := m.Ascent.Ceil(), m.Descent.Ceil()
+	if opts.VMetricAlign {
+		if ascent+descent > opts.Height {
+			return false, nil
+		}
+	} else if ascent > opts.Yoffset || descent > opts.Height-opts.Yoffset {
+		return false, nil
+	}
+	width, _ := opts.cell()
+	for _, v := range runes {
+		x, err := f.GlyphIndex(&buf, v)
+		if err != nil {
+			return false, fmt.Errorf("GlyphIndex: %v", err)
+		}
+		if x == 0 {
+			continue
+		}
+		adv, err := f.GlyphAdvance(&buf, x, fixed.I(ppem), font.HintingNone)
+		if err != nil {
+			return false, fmt.Errorf("GlyphAdvance: %v", err)
+		}
+		if opts.Xoffset+adv.Ceil() > width {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// capHeight returns the cap height of f at ppem in pixels.
+func capHeight(f *sfnt.Font, ppem int) (int, error) {
+	m, err := f.Metrics(nil, fixed.I(ppem), font.HintingNone)
+	if err != nil {
+		return 0, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	return m.CapHeight.Round(), nil
+}
+
+// fitPPEM returns the largest PPEM up to max-ppem at which the runes fit
+// into the cell, and whether they fill its height exactly.
+func fitPPEM(f *sfnt.Font, runes []rune, opts *options) (ppem int, exact bool, err error) {
+	ok := func(ppem int) (bool, error) { return fits(f, runes, opts, ppem) }
+	ppem, err = searchPPEM(opts.MaxPPEM, ok)
+	if err != nil {
+		return 0, false, err
+	}
+	if ppem == 0 {
+		return 0, false, fmt.Errorf("the font does not fit into the cell at any size")
+	}
+	if ppem == opts.MaxPPEM {
+		return 0, false, fmt.Errorf("the font still fits into the cell at max-ppem %d", opts.MaxPPEM)
+	}
+	m, err := f.Metrics(nil, fixed.I(ppem), font.HintingFull)
+	if err != nil {
+		return 0, false, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	return ppem, m.Ascent.Ceil()+m.Descent.Ceil() == opts.Height, nil
+}
+
+// capHeightPPEM returns the largest PPEM up to max-ppem at which the cap
+// height of f does not exceed the target, and whether it matches it.
+func capHeightPPEM(f *sfnt.Font, opts *options) (ppem int, exact bool, err error) {
+	top, err := capHeight(f, opts.MaxPPEM)
+	if err != nil {
+		return 0, false, err
+	}
+	if top < opts.CapHeight {
+		return 0, false, fmt.Errorf("the cap height is %d pixels at max-ppem %d, below the target of %d", top, opts.MaxPPEM, opts.CapHeight)
+	}
+	ok := func(ppem int) (bool, error) {
+		h, err := capHeight(f, ppem)
+		return h <= opts.CapHeight, err
+	}
+	if ppem, err = searchPPEM(opts.MaxPPEM, ok); err != nil {
+		return 0, false, err
+	}
+	if ppem == 0 {
+		return 0, false, fmt.Errorf("the cap height exceeds the target of %d pixels at any size", opts.CapHeight)
+	}
+	h, err := capHeight(f, ppem)
+	return ppem, h == opts.CapHeight, err
+}
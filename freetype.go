@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// FreeType pixel modes, as in FT_Pixel_Mode.
+const (
+	ftPixelModeMono = 1
+	ftPixelModeGray = 2
+)
+
+// ftPitch returns the pitch FreeType uses for a rendered bitmap of the
+// given width: mono rows are padded to 16 bits, gray rows are not padded.
+func ftPitch(width, mode int) int {
+	if mode == ftPixelModeMono {
+		return ((width + 15) >> 4) << 1
+	}
+	return width
+}
+
+// ftBuffer returns the rows of g in the FreeType pixel mode, every row
+// padded to the pitch. Gray levels are scaled to 256 grays.
+func ftBuffer(g *glyph, mode int) []byte {
+	pitch := ftPitch(g.width, mode)
+	buf := make([]byte, pitch*g.height)
+	for y := 0; y < g.height; y++ {
+		row := buf[y*pitch : (y+1)*pitch]
+		if mode == ftPixelModeMono {
+			copy(row, g.data[y*g.rowBytes():(y+1)*g.rowBytes()])
+			continue
+		}
+		for x := 0; x < g.width; x++ {
+			row[x] = uint8(g.level(x, y) * 17)
+		}
+	}
+	return buf
+}
+
+// writeFreetype writes the glyphs as C structs modeled after FT_Bitmap and
+// the metrics of an FT_GlyphSlot: every bitmap is the full cell, its left
+// and top edges are given relative to the pen position on the baseline and
+// the advance is in 26.6 fixed point like in FreeType.
+func writeFreetype(w io.Writer, glyphs []glyph, opts *options) error {
+	out := bufio.NewWriter(w)
+	mode, grays := ftPixelModeMono, 2
+	if opts.Bpp == 4 {
+		mode, grays = ftPixelModeGray, 256
+	}
+
+	fmt.Fprint(out, `#pragma once
+
+#include <stdint.h>
+
+#define FontCustom_PIXEL_MODE_MONO 1
+#define FontCustom_PIXEL_MODE_GRAY 2
+
+`)
+	if c := opts.blockComment("the fields match FT_Bitmap up to pixel_mode"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprint(out, `typedef struct {
+  unsigned int rows;
+  unsigned int width;
+  int pitch;
+  const unsigned char *buffer;
+  unsigned short num_grays;
+  unsigned char pixel_mode;
+} FontCustom_Bitmap;
+
+typedef struct {
+  uint32_t charcode;
+  FontCustom_Bitmap bitmap;
+  int bitmap_left;
+  int bitmap_top;
+  long advance_x;`+opts.trailingComment("26.6 fixed point")+`
+} FontCustom_GlyphSlot;
+
+`)
+	pitch := 0
+	for _, g := range glyphs {
+		pitch = ftPitch(g.width, mode)
+		buf := ftBuffer(&g, mode)
+		fmt.Fprintf(out, "static const unsigned char %s [] =\n{\n", ftBitmapName(g.rune))
+		if c := opts.comment(glyphComment(&g)); c != "" {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+		for y, tmp := range g.art {
+			fmt.Fprint(out, " ")
+			for _, o := range buf[y*pitch : (y+1)*pitch] {
+				fmt.Fprintf(out, " 0x%.2X,", o)
+			}
+			if c := opts.comment(tmp); c != "" {
+				fmt.Fprintf(out, "  %s", c)
+			}
+			fmt.Fprintln(out)
+		}
+		fmt.Fprint(out, "};\n\n")
+	}
+	if c := opts.blockComment("Based on font " + string(opts.Font)); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprint(out, "const FontCustom_GlyphSlot FontCustom_Glyphs [] =\n{\n")
+	for _, g := range glyphs {
+		fmt.Fprintf(out, "  {0x%.4X, {%d, %d, %d, %s, %d, %d}, %d, %d, %d},\n",
+			g.rune, g.height, g.width, pitch, ftBitmapName(g.rune), grays, mode, -opts.Xoffset, g.top, g.advance<<6)
+	}
+	fmt.Fprint(out, "};")
+	if opts.EmbedRenderParams {
+		writeRenderParams(out, opts)
+	}
+	fmt.Fprintf(out, "\n\nconst unsigned int FontCustom_GlyphCount = %d;\n", len(glyphs))
+	return out.Flush()
+}
+
+// ftBitmapName returns the name of the buffer of v in the freetype format.
+func ftBitmapName(v rune) string {
+	return fmt.Sprintf("FontCustom_bitmap_%04X", v)
+}
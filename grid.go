@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+
+	"golang.org/x/image/math/fixed"
+)
+
+// gridOverflow returns an error if the outline with bounds drawn at the
+// origin x,y is not inside a cell of the given size.
+func gridOverflow(v rune, bounds fixed.Rectangle26_6, x, y float32, width, height int) error {
+	left, right := x+float32(bounds.Min.X)/64, x+float32(bounds.Max.X)/64
+	top, bottom := y+float32(bounds.Min.Y)/64, y+float32(bounds.Max.Y)/64
+	if left < 0 || right > float32(width) || top < 0 || bottom > float32(height) {
+		return fmt.Errorf("grid-strict: rune '%c' overflows the %dx%d cell (columns %.1f to %.1f, lines %.1f to %.1f)",
+			v, width, height, left, right, top, bottom)
+	}
+	return nil
+}
+
+// checkGrid returns an error unless every glyph is stored with the full
+// cell, padded to the stride with pad-pow2, and the same baseline.
+func checkGrid(glyphs []glyph, opts *options) error {
+	width, height := opts.storedCell()
+	size := (width*opts.Bpp + 7) / 8 * height
+	if opts.PadPow2 {
+		size = glyphStride(opts)
+	}
+	for _, g := range glyphs {
+		if g.width != width || g.height != height || len(g.storedData()) != size {
+			return fmt.Errorf("grid-strict: the glyph of U+%04X is not stored as a %dx%d cell", g.rune, width, height)
+		}
+		if g.top != glyphs[0].top {
+			return fmt.Errorf("grid-strict: the baseline of U+%04X is at row %d instead of %d", g.rune, g.top, glyphs[0].top)
+		}
+	}
+	return nil
+}
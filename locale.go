@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// block is a range of codepoints.
+type block struct {
+	name     string
+	from, to rune
+}
+
+var (
+	latin1       = block{"Latin-1 Supplement", 0x00A0, 0x00FF}
+	latinExtA    = block{"Latin Extended-A", 0x0100, 0x017F}
+	latinExtAdd  = block{"Latin Extended Additional", 0x1E00, 0x1EFF}
+	greek        = block{"Greek and Coptic", 0x0370, 0x03FF}
+	cyrillic     = block{"Cyrillic", 0x0400, 0x04FF}
+	hebrew       = block{"Hebrew", 0x0590, 0x05FF}
+	generalPunct = block{"General Punctuation", 0x2000, 0x206F}
+)
+
+// localeBlocks maps a language to the blocks rendered in addition to ASCII.
+var localeBlocks = map[string][]block{
+	"ca": {latin1}, "da": {latin1}, "de": {latin1}, "es": {latin1},
+	"fi": {latin1}, "fr": {latin1}, "ga": {latin1}, "is": {latin1},
+	"it": {latin1}, "nb": {latin1}, "nl": {latin1}, "nn": {latin1},
+	"no": {latin1}, "pt": {latin1}, "sv": {latin1},
+
+	"cs": {latin1, latinExtA}, "et": {latin1, latinExtA}, "hr": {latin1, latinExtA},
+	"hu": {latin1, latinExtA}, "lt": {latin1, latinExtA}, "lv": {latin1, latinExtA},
+	"pl": {latin1, latinExtA}, "ro": {latin1, latinExtA}, "sk": {latin1, latinExtA},
+	"sl": {latin1, latinExtA}, "tr": {latin1, latinExtA},
+
+	"vi": {latin1, latinExtA, latinExtAdd},
+
+	"el": {latin1, greek},
+	"be": {cyrillic}, "bg": {cyrillic}, "mk": {cyrillic},
+	"ru": {cyrillic}, "sr": {cyrillic}, "uk": {cyrillic},
+	"he": {hebrew, generalPunct},
+}
+
+// localeLanguage returns the language of a locale name like de_DE.UTF-8.
+// For "auto" the locale is read from the environment.
+func localeLanguage(name string) string {
+	if name == "auto" {
+		name = ""
+		for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+			if name = os.Getenv(env); name != "" {
+				break
+			}
+		}
+	}
+	lang, _, _ := strings.Cut(name, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang, _, _ = strings.Cut(lang, "_")
+	return strings.ToLower(lang)
+}
+
+// localeRunes returns the ASCII runes plus the runes of the blocks of the
+// locale one of the fonts has a glyph for.
+func localeRunes(fonts []*sfnt.Font, name string) ([]rune, error) {
+	runes := asciiRunes()
+	var buf sfnt.Buffer
+	for _, b := range localeBlocks[localeLanguage(name)] {
+		for v := b.from; v <= b.to; v++ {
+			for _, f := range fonts {
+				ok, err := hasGlyph(f, &buf, v)
+				if err != nil {
+					return nil, err
+				}
+				if ok {
+					runes = append(runes, v)
+					break
+				}
+			}
+		}
+	}
+	return runes, nil
+}
@@ -1,33 +1,196 @@
 package main
 
 import (
-	"bytes"
-	"fmt"
 	"image"
-	"image/draw"
-	"io/ioutil"
 	"log"
 	"os"
 
-	"github.com/icza/bitio"
 	flags "github.com/jessevdk/go-flags"
 	"golang.org/x/image/font"
 	"golang.org/x/image/font/sfnt"
 	"golang.org/x/image/math/fixed"
-	"golang.org/x/image/vector"
 )
 
-var conf struct {
-	Width   int            `short:"w" long:"width"   description:"font width in bytes"    default:"2"`
-	Height  int            `short:"h" long:"height"  description:"font height in lines"   default:"24"`
-	PPEM    int            `short:"s" long:"ppem"    description:"font size"              default:"20"`
-	Xoffset int            `short:"x" long:"xoffset" description:"x offset for the runes" default:"0"`
-	Yoffset int            `short:"y" long:"yoffset" description:"y offset for the runes" default:"18"`
-	Font    flags.Filename `short:"f" long:"font"    description:"path to font file"      required:"true"`
-	Debug   bool           `short:"d" long:"debug"   description:"display some debug information"`
+type options struct {
+	Width    int            `short:"w" long:"width"   description:"font width in bytes"    default:"2"`
+	Height   int            `short:"h" long:"height"  description:"font height in lines"   default:"24"`
+	PPEM     int            `short:"s" long:"ppem"    description:"font size"              default:"20"`
+	Xoffset  int            `short:"x" long:"xoffset" description:"x offset for the runes" default:"0"`
+	Yoffset  int            `short:"y" long:"yoffset" description:"y offset for the runes" default:"18"`
+	Font     flags.Filename `short:"f" long:"font"    description:"path to font file, required unless comparing"`
+	Debug    bool           `short:"d" long:"debug"   description:"display some debug information"`
+	Origin   string         `long:"origin"            description:"origin from the font metrics instead of the offsets, like left:2,baseline:20"`
+	TopPad   int            `long:"top-pad"           description:"blank lines stored above every glyph"`
+	Tracking int            `long:"tracking"          description:"pixels added to every advance with a layout and in the strip and freetype formats, or to the cell width otherwise, negative to tighten"`
+
+	Fit       bool `long:"fit"        description:"use the largest font size at which the runes fit into the cell instead of ppem"`
+	CapHeight int  `long:"cap-height" description:"use the largest font size at which the cap height does not exceed this many pixels instead of ppem"`
+	MaxPPEM   int  `long:"max-ppem"   description:"largest font size considered by fit and cap-height" default:"512"`
+
+	CoverageFile flags.Filename `long:"coverage-file" description:"file with the codepoints to render, one U+XXXX or hex value or variation sequence per line"`
+	Locale       string         `long:"locale"        description:"render the blocks used by a locale like de_DE unless runes are selected explicitly, auto reads it from the environment"`
+
+	Fallback     []flags.Filename `long:"fallback"      description:"font to render the runes the font has no glyph for, tried in order (repeatable)"`
+	Manifest     string           `long:"manifest"      description:"write which font every codepoint range was rendered from as a C table or as JSON to the manifest file" choice:"c" choice:"json"`
+	ManifestFile flags.Filename   `long:"manifest-file" description:"file the JSON manifest is written to"`
+
+	Format     string `long:"format" description:"output format: a C sFONT struct, a header-only C++ class, C structs modeled after FreeType's FT_Bitmap, all glyphs in one wide C bitmap with a rect table or a binary file" choice:"c" choice:"cpp" choice:"freetype" choice:"strip" choice:"bin" default:"c"`
+	Constexpr  bool   `long:"constexpr"   description:"with the cpp format, write the glyph data and the glyph accessor as constexpr"`
+	BinIndexed bool   `long:"bin-indexed" description:"with the bin format, start with an index of the offset and length of every glyph for random access"`
+
+	Indent     string `long:"indent"      description:"indentation of the output: a number of spaces or tab" default:"2"`
+	BraceStyle string `long:"brace-style" description:"placement of opening braces: as in the classic sFONT files, attached to the line before or on their own line" choice:"default" choice:"attach" choice:"break" default:"default"`
+
+	CommentStyle string `long:"comment-style" description:"style of the comments in the output" choice:"line" choice:"block" choice:"none" default:"line"`
+
+	Sentinel string `long:"sentinel" description:"hex byte pattern appended to the table as a terminator" value-name:"HEX"`
+
+	SeparateGlyphs bool `long:"separate-glyphs" description:"write every glyph as its own array so the linker can drop unused ones"`
+
+	TwoPlane bool     `long:"two-plane" description:"write a black and a red plane for tri-color panels"`
+	Red      []string `long:"red"       description:"codepoint or range like U+0030-U+0039 rendered into the red plane, or all (repeatable)"`
+
+	ContiguousGlyphs bool `long:"contiguous-glyphs" description:"write the offset of every glyph's contiguous byte span in the table"`
+
+	PerGlyphCRC string `long:"per-glyph-crc" description:"write a CRC-8 or CRC-16 of every glyph" choice:"8" choice:"16" value-name:"BITS"`
+
+	PHF bool `long:"phf" description:"write a minimal perfect hash function mapping codepoints to glyph indices"`
+
+	AutoCompress bool   `long:"auto-compress" description:"store every glyph raw or run-length encoded, whichever is smaller"`
+	RLEUnit      string `long:"rle-unit"      description:"run-length encode runs of equal bytes or of equal bits" choice:"byte" choice:"bit" default:"byte"`
+
+	PadPow2 bool `long:"pad-pow2" description:"zero-fill every glyph to the next power of two bytes so glyph i starts at i << shift"`
+
+	RaggedRows bool `long:"ragged-rows" description:"store every row as its used bit count followed by these bits, packed back to back"`
+
+	IndexDelta bool `long:"index-delta" description:"store the offsets of the index and of contiguous-glyphs as differences to the previous offset"`
+
+	Layout string `long:"layout" description:"write the offset and advance of every glyph as an array of structs or a struct of arrays" choice:"aos" choice:"soa"`
+
+	Preview flags.Filename `long:"preview" description:"write the sample text rendered with the glyphs to this PNG file" value-name:"FILE"`
+	Sample  string         `long:"sample"  description:"text of the preview" default:"The quick brown fox jumps over the lazy dog"`
+	Sizes   string         `long:"sizes"   description:"render the preview once per font size like 12,16,20,24, each in a cell fitted to it" value-name:"LIST"`
+
+	SizeReport bool `long:"size-report" description:"print the stored size of every glyph, largest first"`
+
+	Compare     bool           `long:"compare"     description:"compare the glyphs of the two generated c headers given as arguments instead of generating one"`
+	CompareArt  bool           `long:"compare-art"  description:"with compare or compare-strikes, show the ASCII art of differing glyphs side by side"`
+	PreviewDiff flags.Filename `long:"preview-diff" description:"with compare, write a PNG of all glyphs with ink only the second header has in green and ink only the first has in red"`
+
+	CompareStrikes  bool   `long:"compare-strikes"  description:"compare the rendered glyphs with the embedded EBDT bitmap strike of the font at the ppem instead of writing them"`
+	VerifyAgainst   string `long:"verify-against"   description:"compare every glyph with the reference image U+XXXX.png in this directory" value-name:"DIR"`
+	VerifyTolerance int    `long:"verify-tolerance" description:"number of pixels a glyph may differ from its reference"`
+
+	EmitTest        flags.Filename `long:"emit-test"         description:"write a host C program to this file which prints every glyph read from the table and checks it against the generated art"`
+	EmitTestInclude string         `long:"emit-test-include" description:"file name under which the test program includes the generated font" default:"font.c"`
+
+	GridStrict bool `long:"grid-strict" description:"fail unless every glyph fits the cell at the common origin and is stored as the full cell"`
+
+	EmbedRenderParams bool `long:"embed-render-params" description:"write the bpp, levels, threshold, gamma and contrast of the render as FontCustom_RenderParams"`
+
+	SkipEmptyRows bool `long:"skip-empty-rows" description:"store every glyph as a mask of its rows with ink followed by only these rows, with an index"`
+
+	SubpixelPhases int `long:"subpixel-phases" description:"render every glyph at this many horizontal subpixel positions, stored next to each other" value-name:"N" default:"1"`
+
+	FixedWidthVarHeight bool `long:"fixed-width-var-height" description:"store only the rows of every glyph from its first to its last row with ink, with their offset and count in the index"`
+
+	AlwaysIndex bool `long:"always-index" description:"write the codepoints and the glyph index even for the contiguous ASCII range"`
+
+	RotateMap flags.Filename `long:"rotate-map" description:"file with a codepoint and a clockwise rotation of 0, 90, 180 or 270 degrees per line, every glyph is rotated within the cell"`
+
+	Clip string `long:"clip" description:"clear the pixels outside of this rectangle of the stored cell" value-name:"X,Y,W,H"`
+
+	OrderForCompression bool `long:"order-for-compression" description:"place similar glyphs next to each other in the table for whole-table compressors, with a remap table from codepoint order"`
+
+	SelfCheck bool `long:"self-check" description:"render all glyphs twice and fail if the bytes differ"`
+
+	Variants []string `long:"variant" description:"render the glyph selected by a variation sequence like \"U+2764 U+FE0F\" into the slot of its base codepoint, which is added to the selection (repeatable)"`
+
+	Align     int    `long:"align"      description:"align the table to n bytes and pad its size to a multiple of n"`
+	AlignAttr string `long:"align-attr" description:"attribute used for the alignment, %d is replaced by n" default:"__attribute__((aligned(%d)))"`
+
+	AlignGlyph string `long:"align-glyph" description:"move the ink of every glyph flush to the left or right edge of the cell or center it" choice:"left" choice:"right" choice:"center"`
+
+	RespectBearings bool `long:"respect-bearings" description:"place every glyph in the cell according to its side bearings"`
+
+	TabularFigures bool `long:"tabular-figures" description:"give the digits 0-9 a common advance and center them in it"`
+
+	SnapOrigin bool `long:"snap-origin" description:"round the origin of every glyph to the pixel grid"`
+	SnapGrid   int  `long:"snap-grid"   description:"with snap-origin, round to 1/n of a pixel instead" default:"1"`
+
+	VMetricAlign bool `long:"vmetric-align" description:"place the baseline using the font ascent and descent instead of the y offset"`
+
+	OvershootTrim float64 `long:"overshoot-trim" description:"shave up to this many pixels of overshoot of round glyphs above the cap or x-height and below the baseline" value-name:"PX"`
+
+	Scale            int    `long:"scale"             description:"rasterize at this factor and downsample the result" default:"1"`
+	LinearDownsample bool   `long:"linear-downsample" description:"average supersampled coverage in linear light"`
+	Downsample       string `long:"downsample"        description:"filter reducing the supersampled coverage" choice:"box" choice:"triangle" choice:"gaussian" default:"box"`
+
+	SmallSizeBoost bool    `long:"small-size-boost" description:"below small-size-ppem, raise faint coverage so thin strokes survive thresholding"`
+	SmallSizePPEM  int     `long:"small-size-ppem"  description:"ppem below which small-size-boost applies" default:"14"`
+	SmallSizeGamma float64 `long:"small-size-gamma" description:"gamma of the small-size-boost curve, higher boosts more" default:"1.8"`
+
+	Pipeline string `long:"pipeline" description:"transforms applied in order to the coverage of every glyph, like shear=12,bold=1,scale=2" value-name:"STAGES"`
+
+	Contrast string `long:"contrast" description:"stretch the coverage so that low,high becomes 0,255 before thresholding" value-name:"LOW,HIGH"`
+
+	Bpp         int    `long:"bpp"          description:"bits per pixel: thresholded or 16 gray levels" choice:"1" choice:"4" default:"1"`
+	NibbleOrder string `long:"nibble-order" description:"with 4 bpp, whether the first pixel of a byte is its high or its low nibble" choice:"high" choice:"low" default:"high"`
+
+	ThresholdGradient string `long:"threshold-gradient" description:"experimental: ramp the coverage threshold of 1 bpp glyphs linearly from the center of the cell to its left and right edges" value-name:"EDGE,CENTER"`
+
+	SoftEdges bool `long:"soft-edges" description:"stipple the antialiased edge band instead of thresholding it"`
+	SoftLow   int  `long:"soft-low"   description:"alpha at or below which an edge pixel is always blank" default:"32"`
+	SoftHigh  int  `long:"soft-high"  description:"alpha at or above which an edge pixel is always set"  default:"192"`
+
+	// sentinel holds the parsed bytes of Sentinel.
+	sentinel []byte
+
+	// origin holds the parsed Origin.
+	origin origin
+
+	// pipeline holds the parsed stages of Pipeline.
+	pipeline []stage
+
+	// contrast holds the parsed bounds of Contrast, if set.
+	contrast *[2]int
+
+	// indent holds one level of the parsed Indent, if set.
+	indent *string
+
+	// red holds the codepoints of Red, redAll is set if Red contains all.
+	red    map[rune]bool
+	redAll bool
+
+	// variants holds the glyphs resolved from Variants by base codepoint.
+	variants map[rune]sfnt.GlyphIndex
+
+	// fallbacks holds the parsed Fallback fonts.
+	fallbacks []*sfnt.Font
+
+	// sizes holds the parsed Sizes.
+	sizes []int
+
+	// gradient holds the parsed edge and center of ThresholdGradient, if set.
+	gradient *[2]int
+
+	// clip holds the parsed Clip rectangle, if set.
+	clip *image.Rectangle
+
+	// rotations holds the angles read from RotateMap by codepoint.
+	rotations map[rune]int
+
+	// order holds the table slots of the glyphs with OrderForCompression.
+	order []int
+}
+
+var conf options
+
+func newParser(opts *options) *flags.Parser {
+	return flags.NewParser(opts, flags.Default)
 }
 
-var parser = flags.NewParser(&conf, flags.Default)
+var parser = newParser(&conf)
 
 func main() {
 	args, err := parser.Parse()
@@ -38,11 +201,198 @@ func main() {
 			os.Exit(1)
 		}
 	}
+	if conf.Compare {
+		if len(args) != 2 {
+			log.Fatal("compare requires two headers")
+		}
+		a, err := readHeader(args[0])
+		if err != nil {
+			log.Fatal(err)
+		}
+		b, err := readHeader(args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		if conf.PreviewDiff != "" {
+			if err := writePreviewDiff(string(conf.PreviewDiff), a, b); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if compareHeaders(os.Stdout, a, b, conf.CompareArt) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
 	if len(args) > 0 {
 		log.Fatal("do not provide additional parameters")
 	}
+	if conf.Font == "" {
+		log.Fatal("the font is required")
+	}
+	if conf.PreviewDiff != "" || (conf.CompareArt && !conf.CompareStrikes) {
+		log.Fatal("compare-art and preview-diff require compare, compare-art is also supported by compare-strikes")
+	}
+	if conf.CompareStrikes && conf.Bpp != 1 {
+		log.Fatal("compare-strikes requires 1 bpp")
+	}
+	if conf.TopPad < 0 {
+		log.Fatal("top-pad must not be negative")
+	}
+	if width, _ := conf.cell(); width < 1 {
+		log.Fatal("tracking leaves no column of the cell")
+	}
+	if conf.Fit && conf.CapHeight != 0 {
+		log.Fatal("fit and cap-height are mutually exclusive")
+	}
+	if conf.CapHeight < 0 || conf.MaxPPEM < 1 {
+		log.Fatal("cap-height must not be negative and max-ppem must be at least 1")
+	}
+	if conf.SnapGrid < 1 {
+		log.Fatal("snap-grid must be at least 1")
+	}
+	if conf.OvershootTrim < 0 {
+		log.Fatal("overshoot-trim must not be negative")
+	}
+	if conf.Scale < 1 {
+		log.Fatal("scale must be at least 1")
+	}
+	if conf.LinearDownsample && conf.Scale == 1 {
+		log.Fatal("linear-downsample requires a scale above 1")
+	}
+	if conf.Downsample != "box" && conf.Scale == 1 {
+		log.Fatal("downsample requires a scale above 1")
+	}
+	if conf.Align < 0 {
+		log.Fatal("align must not be negative")
+	}
+	if err := checkAlignAttr(conf.AlignAttr); err != nil {
+		log.Fatal(err)
+	}
+	if conf.Sentinel != "" {
+		if conf.sentinel, err = parseHex(conf.Sentinel); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.Origin != "" {
+		if conf.origin, err = parseOrigin(conf.Origin); err != nil {
+			log.Fatal(err)
+		}
+		if conf.origin.y != "" && conf.VMetricAlign {
+			log.Fatal("a vertical origin and vmetric-align are mutually exclusive")
+		}
+		if conf.origin.x != "" && conf.RespectBearings {
+			log.Fatal("a horizontal origin and respect-bearings are mutually exclusive")
+		}
+		if conf.Fit && conf.origin.y != "" && conf.origin.y != "baseline" {
+			log.Fatal("fit requires a fixed baseline")
+		}
+		if conf.Fit && conf.origin.x == "right" {
+			log.Fatal("fit requires a fixed pen position, it excludes a right origin")
+		}
+		if conf.origin.y == "baseline" {
+			conf.Yoffset = conf.origin.yVal
+		}
+		if conf.origin.x == "left" {
+			conf.Xoffset = conf.origin.xVal
+		}
+	}
+	if err := checkFlags(&conf); err != nil {
+		log.Fatal(err)
+	}
+	if conf.AlignGlyph != "" && (conf.RespectBearings || conf.origin.x != "") {
+		log.Fatal("align-glyph places the ink itself, it excludes respect-bearings and a horizontal origin")
+	}
+	if conf.SmallSizeBoost && conf.SmallSizeGamma < 1 {
+		log.Fatal("small-size-gamma must be at least 1")
+	}
+	if conf.ThresholdGradient != "" {
+		if conf.Bpp != 1 || conf.SoftEdges {
+			log.Fatal("threshold-gradient requires 1 bpp without soft-edges")
+		}
+		g, err := parseGradient(conf.ThresholdGradient)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.gradient = &g
+	}
+	if conf.Clip != "" {
+		c, err := parseClip(conf.Clip)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if width, height := conf.storedCell(); !c.In(image.Rect(0, 0, width, height)) {
+			log.Fatalf("clip %s exceeds the %dx%d cell", conf.Clip, width, height)
+		}
+		conf.clip = &c
+	}
+	if conf.RotateMap != "" {
+		if conf.rotations, err = readRotateMap(string(conf.RotateMap)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.Pipeline != "" {
+		if conf.pipeline, err = parsePipeline(conf.Pipeline); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.Contrast != "" {
+		c, err := parseContrast(conf.Contrast)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.contrast = &c
+	}
+	if conf.Indent != "" {
+		indent, err := parseIndent(conf.Indent)
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.indent = &indent
+	}
+	if conf.Manifest == "c" && conf.Format != "c" {
+		log.Fatal("the c manifest is only supported by the c format")
+	}
+	if (conf.Manifest == "json") != (conf.ManifestFile != "") {
+		log.Fatal("manifest-file is required by and only used with the json manifest")
+	}
+	if conf.RaggedRows && conf.Bpp != 1 {
+		log.Fatal("ragged-rows requires 1 bpp")
+	}
+	if conf.SubpixelPhases < 1 || conf.SubpixelPhases > 16 {
+		log.Fatal("subpixel-phases must be between 1 and 16")
+	}
+	if conf.IndexDelta && !conf.AutoCompress && conf.Layout == "" && !conf.ContiguousGlyphs && !conf.RaggedRows && !conf.AlwaysIndex && conf.RotateMap == "" && !conf.SkipEmptyRows && !conf.FixedWidthVarHeight {
+		log.Fatal("index-delta requires auto-compress, layout, ragged-rows, skip-empty-rows, fixed-width-var-height, contiguous-glyphs, always-index or rotate-map")
+	}
+	if len(conf.Red) > 0 && !conf.TwoPlane {
+		log.Fatal("red requires two-plane")
+	}
+	if conf.TwoPlane {
+		conf.red = map[rune]bool{}
+		for _, spec := range conf.Red {
+			if spec == "all" {
+				conf.redAll = true
+			} else if err := parseRuneSet([]string{spec}, conf.red); err != nil {
+				log.Fatal(err)
+			}
+		}
+	}
+	if conf.Sizes != "" {
+		if conf.Preview == "" {
+			log.Fatal("sizes requires preview")
+		}
+		if conf.sizes, err = parseSizes(conf.Sizes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.Bpp == 4 && conf.SoftEdges {
+		log.Fatal("soft-edges requires 1 bpp")
+	}
+	if conf.SoftLow < 0 || conf.SoftHigh > 255 || conf.SoftLow >= conf.SoftHigh {
+		log.Fatal("soft edge bounds must satisfy 0 <= soft-low < soft-high <= 255")
+	}
 	// Read the font data.
-	fontBytes, err := ioutil.ReadFile(string(conf.Font))
+	fontBytes, err := os.ReadFile(string(conf.Font))
 	if err != nil {
 		log.Println(err)
 		return
@@ -51,6 +401,41 @@ func main() {
 	if err != nil {
 		log.Fatalf("Parse: %v", err)
 	}
+	if conf.fallbacks, err = loadFallbacks(&conf); err != nil {
+		log.Fatal(err)
+	}
+
+	runes, err := selectRunes(f, &conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(conf.Variants) > 0 {
+		conf.variants, err = resolveVariants(fontBytes, conf.Variants)
+		if err != nil {
+			log.Fatalf("variant: %v", err)
+		}
+	}
+	if conf.Fit || conf.CapHeight > 0 {
+		var ppem int
+		var exact bool
+		if conf.Fit {
+			ppem, exact, err = fitPPEM(f, runes, &conf)
+		} else {
+			ppem, exact, err = capHeightPPEM(f, &conf)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		conf.PPEM = ppem
+		if exact {
+			log.Printf("ppem %d, the target is met exactly", ppem)
+		} else {
+			log.Printf("ppem %d, the closest size below the target", ppem)
+		}
+	}
+	if err := resolveOrigin(f, &conf); err != nil {
+		log.Fatal(err)
+	}
 
 	if conf.Debug {
 		i, err := f.Metrics(nil, fixed.I(conf.PPEM), font.HintingFull)
@@ -72,103 +457,82 @@ func main() {
 		log.Println("  Yoffset:", conf.Yoffset)
 	}
 
-	fmt.Println(`#include "fonts.h"
-#if defined(__AVR__) || defined(ARDUINO_ARCH_SAMD)
-#include <avr/pgmspace.h>
-#elif defined(ESP8266) || defined(ESP32)
-#include <pgmspace.h>
-#endif
-
-const uint8_t FontCustom_Table [] PROGMEM =
-{
-`)
-	width := conf.Width * 8
-	height := conf.Height
-	for i := 32; i <= 126; i++ { // only printable chars
-		v := rune(i)
-		x, err := f.GlyphIndex(nil, v)
+	if conf.VMetricAlign && conf.Debug {
+		b, err := baseline(f, &conf)
 		if err != nil {
-			log.Fatalf("GlyphIndex: %v", err)
-		}
-		if x == 0 {
-			log.Fatalf("GlyphIndex: no glyph index found for the rune '", v, "'")
+			log.Fatal(err)
 		}
+		log.Println("  baseline:", b)
+	}
 
-		originX := float32(conf.Xoffset)
-		originY := float32(conf.Yoffset)
-
-		segments, err := f.LoadGlyph(nil, x, fixed.I(conf.PPEM), nil)
+	glyphs, err := renderGlyphs(f, runes, &conf)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if conf.VerifyAgainst != "" {
+		if n := verifyGlyphs(conf.VerifyAgainst, glyphs, conf.VerifyTolerance); n > 0 {
+			log.Fatalf("%d of %d glyphs do not match the references", n, len(glyphs))
+		}
+	}
+	if conf.CompareStrikes {
+		s, err := readStrike(fontBytes, conf.PPEM)
 		if err != nil {
-			log.Fatalf("LoadGlyph: %v", err)
-		}
-		r := vector.NewRasterizer(width, height)
-		r.DrawOp = draw.Src
-		for _, seg := range segments {
-			// The divisions by 64 below is because the seg.Args values have type
-			// fixed.Int26_6, a 26.6 fixed point number, and 1<<6 == 64.
-			switch seg.Op {
-			case sfnt.SegmentOpMoveTo:
-				r.MoveTo(
-					originX+float32(seg.Args[0].X)/64,
-					originY+float32(seg.Args[0].Y)/64,
-				)
-			case sfnt.SegmentOpLineTo:
-				r.LineTo(
-					originX+float32(seg.Args[0].X)/64,
-					originY+float32(seg.Args[0].Y)/64,
-				)
-			case sfnt.SegmentOpQuadTo:
-				r.QuadTo(
-					originX+float32(seg.Args[0].X)/64,
-					originY+float32(seg.Args[0].Y)/64,
-					originX+float32(seg.Args[1].X)/64,
-					originY+float32(seg.Args[1].Y)/64,
-				)
-			case sfnt.SegmentOpCubeTo:
-				r.CubeTo(
-					originX+float32(seg.Args[0].X)/64,
-					originY+float32(seg.Args[0].Y)/64,
-					originX+float32(seg.Args[1].X)/64,
-					originY+float32(seg.Args[1].Y)/64,
-					originX+float32(seg.Args[2].X)/64,
-					originY+float32(seg.Args[2].Y)/64,
-				)
-			default:
-				log.Fatal("OP: ", seg.Op)
-			}
+			log.Fatalf("compare-strikes: %v", err)
 		}
-		dst := image.NewAlpha(image.Rect(0, 0, width, height))
-		r.Draw(dst, dst.Bounds(), image.Opaque, image.Point{})
-		fmt.Printf("  // %c %d\n", v, v)
-		for y := 0; y < height; y++ {
-			b := &bytes.Buffer{}
-			w := bitio.NewWriter(b)
-			tmp := ""
-			for x := 0; x < width; x++ {
-				a := dst.AlphaAt(x, y).A
-				if a < 64 {
-					w.WriteBits(0, 1)
-					tmp += "."
-				} else {
-					w.WriteBits(1, 1)
-					tmp += "#"
-				}
-			}
-			w.Close()
-			fmt.Printf("  ")
-			for _, o := range b.Bytes() {
-				fmt.Printf("0x%.2X, ", o)
-			}
-			fmt.Printf(" // %s", tmp)
-			fmt.Println()
-		}
-	}
-	fmt.Printf(`};`)
-	fmt.Printf("\n\n/* Based on font %s */\n", string(conf.Font))
-	fmt.Printf(`sFONT FontCustom = {
-  FontCustom_Table,
-  %d, /* Width */
-  %d, /* Height */
-};
-`, width, height)
+		strikes, err := strikeHeader(f, s, glyphs, &conf)
+		if err != nil {
+			log.Fatalf("compare-strikes: %v", err)
+		}
+		if compareHeaders(os.Stdout, outlineHeader(glyphs, &conf), strikes, conf.CompareArt) > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+	if conf.GridStrict {
+		if err := checkGrid(glyphs, &conf); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.OrderForCompression {
+		conf.order = compressionOrder(glyphs)
+	}
+	if conf.SelfCheck {
+		n, err := selfCheck(f, runes, glyphs, &conf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if n > 0 {
+			log.Fatalf("self-check: %d divergences, the render is not deterministic", n)
+		}
+	}
+	if err := write(os.Stdout, glyphs, &conf); err != nil {
+		log.Fatal(err)
+	}
+	if conf.Manifest == "json" {
+		if err := writeManifestFile(glyphs, &conf); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.EmitTest != "" {
+		if err := writeTestFile(glyphs, &conf); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.Preview != "" {
+		if err := writePreview(f, &conf, conf.sizes); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if conf.PadPow2 {
+		writePaddingReport(os.Stderr, glyphs, &conf)
+	}
+	if conf.SizeReport {
+		writeSizeReport(os.Stderr, glyphs)
+	}
+	if conf.AutoCompress {
+		writeCompressionSummary(os.Stderr, glyphs, conf.RLEUnit)
+	}
+	if conf.OrderForCompression {
+		writeOrderReport(os.Stderr, glyphs, conf.order)
+	}
 }
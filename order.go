@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// hamming returns the number of bits in which a and b differ.
+func hamming(a, b []byte) int {
+	n := 0
+	for i := range a {
+		n += bits.OnesCount8(a[i] ^ b[i])
+	}
+	return n
+}
+
+// compressionOrder returns the glyphs in an order which places similar
+// bitmaps next to each other: starting with the first glyph it greedily
+// appends the unplaced glyph closest in Hamming distance to the last one.
+// Ties keep the codepoint order.
+func compressionOrder(glyphs []glyph) []int {
+	order := make([]int, 0, len(glyphs))
+	placed := make([]bool, len(glyphs))
+	for last := 0; len(order) < len(glyphs); {
+		order = append(order, last)
+		placed[last] = true
+		next, best := -1, 0
+		for i := range glyphs {
+			if placed[i] {
+				continue
+			}
+			if d := hamming(glyphs[last].data, glyphs[i].data); next < 0 || d < best {
+				next, best = i, d
+			}
+		}
+		last = next
+	}
+	return order
+}
+
+// reorder returns the glyphs in the given order.
+func reorder(glyphs []glyph, order []int) []glyph {
+	out := make([]glyph, len(order))
+	for i, j := range order {
+		out[i] = glyphs[j]
+	}
+	return out
+}
+
+// writeRemap writes the slot in FontCustom_Table of every glyph in
+// codepoint order.
+func writeRemap(out io.Writer, order []int) {
+	slots := make([]int, len(order))
+	for slot, i := range order {
+		slots[i] = slot
+	}
+	fmt.Fprintf(out, "\n\nconst %s FontCustom_Remap [] PROGMEM =\n{\n", uintType(slots))
+	for i, slot := range slots {
+		if i%16 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		fmt.Fprintf(out, " %d,", slot)
+	}
+	fmt.Fprint(out, "\n};")
+}
+
+// deflateSize returns the size of the glyph data compressed as a whole
+// with deflate.
+func deflateSize(glyphs []glyph) int {
+	b := &bytes.Buffer{}
+	w, _ := flate.NewWriter(b, flate.BestCompression)
+	for _, g := range glyphs {
+		w.Write(g.data)
+	}
+	w.Close()
+	return b.Len()
+}
+
+// writeOrderReport writes the deflate size of the table in codepoint order
+// and in the compression order to w.
+func writeOrderReport(w io.Writer, glyphs []glyph, order []int) {
+	before, after := deflateSize(glyphs), deflateSize(reorder(glyphs, order))
+	fmt.Fprintf(w, "order-for-compression: deflate %d bytes in codepoint order, %d bytes reordered (%.1f%% smaller)\n",
+		before, after, 100*float64(before-after)/float64(max(before, 1)))
+}
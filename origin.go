@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// originAxes maps the keys of an origin to their axis.
+var originAxes = map[string]string{
+	"left":     "x", // pen position in pixels from the left edge
+	"right":    "x", // end of the advance in pixels from the right edge
+	"baseline": "y", // row of the baseline
+	"top":      "y", // row of the ascent line
+	"bottom":   "y", // rows from the descent line to the bottom edge
+	"cap":      "y", // row of the cap height
+}
+
+// origin is a parsed origin with at most one key per axis.
+type origin struct {
+	x, y       string
+	xVal, yVal int
+}
+
+// parseOrigin parses an origin like left:2,baseline:20.
+func parseOrigin(s string) (origin, error) {
+	var o origin
+	for _, spec := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(spec), ":")
+		axis, known := originAxes[key]
+		if !ok || !known {
+			return o, fmt.Errorf("invalid origin %q, want left, right, baseline, top, bottom or cap followed by :PIXELS", spec)
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return o, fmt.Errorf("invalid pixels in origin %q", spec)
+		}
+		if axis == "x" {
+			if o.x != "" {
+				return o, fmt.Errorf("origin %q has more than one horizontal key", s)
+			}
+			o.x, o.xVal = key, n
+		} else {
+			if o.y != "" {
+				return o, fmt.Errorf("origin %q has more than one vertical key", s)
+			}
+			o.y, o.yVal = key, n
+		}
+	}
+	return o, nil
+}
+
+// resolveOrigin sets the offsets of opts from the origin and the metrics
+// of f at the configured size. The right origin depends on the advance of
+// every glyph and is resolved when rendering.
+func resolveOrigin(f *sfnt.Font, opts *options) error {
+	o := opts.origin
+	if o.x == "left" {
+		opts.Xoffset = o.xVal
+	}
+	if o.y == "" {
+		return nil
+	}
+	m, err := f.Metrics(nil, fixed.I(opts.PPEM), font.HintingFull)
+	if err != nil {
+		return fmt.Errorf("could not get font metrics: %v", err)
+	}
+	switch o.y {
+	case "baseline":
+		opts.Yoffset = o.yVal
+	case "top":
+		opts.Yoffset = o.yVal + m.Ascent.Ceil()
+	case "bottom":
+		opts.Yoffset = opts.Height - o.yVal - m.Descent.Ceil()
+	case "cap":
+		if m.CapHeight == 0 {
+			return fmt.Errorf("the font has no cap height for the origin")
+		}
+		opts.Yoffset = o.yVal + m.CapHeight.Round()
+	}
+	return nil
+}
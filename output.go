@@ -0,0 +1,562 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// renderGlyphs renders runes with font f, or with the first fallback font
+// which has a glyph for a rune f lacks.
+func renderGlyphs(f *sfnt.Font, runes []rune, opts *options) ([]glyph, error) {
+	r, err := newRenderer(f, opts)
+	if err != nil {
+		return nil, err
+	}
+	renderers := []*renderer{r}
+	for _, fb := range opts.fallbacks {
+		r, err := newRenderer(fb, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.variants = nil // the variants are glyphs of the primary font
+		renderers = append(renderers, r)
+	}
+	glyphs := make([]glyph, 0, len(runes))
+	for _, v := range runes {
+		source := 0
+		if len(renderers) > 1 {
+			if source, err = glyphSource(renderers, v); err != nil {
+				return nil, err
+			}
+		}
+		if opts.SubpixelPhases > 1 {
+			r := renderers[source]
+			for phase := 0; phase < opts.SubpixelPhases; phase++ {
+				r.phase = float32(phase) / float32(opts.SubpixelPhases)
+				g, err := r.glyph(v)
+				if err != nil {
+					return nil, err
+				}
+				g.source, g.phase, g.phases = source, phase, opts.SubpixelPhases
+				glyphs = append(glyphs, g)
+			}
+			r.phase = 0
+			continue
+		}
+		g, err := renderers[source].glyph(v)
+		if err != nil {
+			return nil, err
+		}
+		g.source = source
+		glyphs = append(glyphs, g)
+	}
+	if opts.PadPow2 {
+		padPow2(glyphs, opts)
+	}
+	if opts.AutoCompress {
+		autoCompress(glyphs, opts.RLEUnit)
+	}
+	if opts.RaggedRows {
+		raggedRows(glyphs)
+	}
+	if opts.SkipEmptyRows {
+		skipEmptyRows(glyphs)
+	}
+	if opts.FixedWidthVarHeight {
+		trimRows(glyphs)
+	}
+	return glyphs, nil
+}
+
+// write writes the glyphs to w in the configured format and style.
+func write(w io.Writer, glyphs []glyph, opts *options) error {
+	if opts.Format == "bin" {
+		return writeBin(w, glyphs, opts)
+	}
+	sw := newStyleWriter(w, opts)
+	var err error
+	switch opts.Format {
+	case "cpp":
+		err = writeCpp(sw, glyphs, opts)
+	case "freetype":
+		err = writeFreetype(sw, glyphs, opts)
+	case "strip":
+		err = writeStrip(sw, glyphs, opts)
+	default:
+		err = writeC(sw, glyphs, opts)
+	}
+	if c, ok := sw.(io.Closer); ok {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// writeC writes the glyphs as sFONT C source to w.
+func writeC(w io.Writer, glyphs []glyph, opts *options) error {
+	out := bufio.NewWriter(w)
+	width, height := opts.storedCell()
+
+	fmt.Fprint(out, `#include "fonts.h"
+#if defined(__AVR__) || defined(ARDUINO_ARCH_SAMD)
+#include <avr/pgmspace.h>
+#elif defined(ESP8266) || defined(ESP32)
+#include <pgmspace.h>
+#endif
+
+`)
+	switch {
+	case opts.SeparateGlyphs:
+		writeSeparateGlyphs(out, glyphs, opts)
+	case opts.TwoPlane:
+		if c := opts.blockComment("black plane"); c != "" {
+			fmt.Fprintln(out, c)
+		}
+		writeTable(out, "FontCustom_Table", planeGlyphs(glyphs, opts, false), opts)
+		fmt.Fprint(out, "\n\n")
+		if c := opts.blockComment("red plane, same layout as the black plane"); c != "" {
+			fmt.Fprintln(out, c)
+		}
+		writeTable(out, "FontCustom_RedTable", planeGlyphs(glyphs, opts, true), opts)
+	case opts.order != nil:
+		if c := opts.blockComment("glyphs in the order of FontCustom_Remap"); c != "" {
+			fmt.Fprintln(out, c)
+		}
+		writeTable(out, "FontCustom_Table", reorder(glyphs, opts.order), opts)
+	default:
+		writeTable(out, "FontCustom_Table", glyphs, opts)
+	}
+	if runes := glyphRunes(glyphs); !contiguousASCII(runes) || opts.AlwaysIndex {
+		writeCodepoints(out, runes)
+	}
+	if opts.order != nil {
+		writeRemap(out, opts.order)
+	}
+	if opts.AutoCompress || opts.Layout != "" || opts.RaggedRows || opts.rotations != nil || opts.AlwaysIndex || opts.SkipEmptyRows || opts.FixedWidthVarHeight {
+		writeIndex(out, glyphs, opts)
+	}
+	if opts.RaggedRows {
+		writeRaggedDecoder(out, opts)
+	}
+	if opts.SkipEmptyRows {
+		writeRowMaskDecoder(out, opts)
+	}
+	if opts.AutoCompress {
+		writeRLEDecoder(out, opts.RLEUnit, opts)
+	}
+	if opts.ContiguousGlyphs {
+		writeOffsets(out, glyphs, opts)
+	}
+	if opts.PerGlyphCRC != "" {
+		writeGlyphCRCs(out, glyphs, opts)
+	}
+	if opts.Manifest == "c" {
+		writeManifestC(out, glyphs, opts)
+	}
+	if opts.PHF {
+		if err := writePHF(out, glyphRunes(glyphs), opts); err != nil {
+			return err
+		}
+	}
+	if opts.EmbedRenderParams {
+		writeRenderParams(out, opts)
+	}
+	if len(opts.sentinel) > 0 {
+		fmt.Fprintf(out, "\n\n#define FontCustom_SentinelSize %d", len(opts.sentinel))
+	}
+	if opts.PadPow2 {
+		stride := glyphStride(opts)
+		fmt.Fprintf(out, "\n\n#define FontCustom_GlyphStride %d\n#define FontCustom_GlyphShift %d%s", stride, bits.TrailingZeros(uint(stride)),
+			opts.trailingComment("glyph i starts at FontCustom_Table[i << FontCustom_GlyphShift]"))
+	}
+	if opts.SubpixelPhases > 1 {
+		fmt.Fprintf(out, "\n\n#define FontCustom_Phases %d%s", opts.SubpixelPhases,
+			opts.trailingComment("phase p of glyph i is slot i * FontCustom_Phases + p"))
+	}
+	if opts.Bpp != 1 {
+		fmt.Fprintf(out, "\n\n#define FontCustom_Bpp %d%s", opts.Bpp, opts.trailingComment(opts.NibbleOrder+" nibble first"))
+		fmt.Fprintf(out, "\n#define FontCustom_LowNibbleFirst %d%s", opts.lowNibbleFirst(), opts.trailingComment("1 if the first pixel of a byte is its low nibble"))
+	}
+	fmt.Fprint(out, "\n\n")
+	if c := opts.blockComment("Based on font " + string(opts.Font)); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	if opts.SeparateGlyphs {
+		// without a single table there is no sFONT struct
+		fmt.Fprintf(out, "#define FontCustom_Width %d\n#define FontCustom_Height %d\n", width, height)
+		return out.Flush()
+	}
+	writeStruct(out, "FontCustom", "FontCustom_Table", width, height, opts)
+	if opts.TwoPlane {
+		fmt.Fprintln(out)
+		writeStruct(out, "FontCustom_Red", "FontCustom_RedTable", width, height, opts)
+	}
+	return out.Flush()
+}
+
+// writeTable writes the glyph data as the array name.
+func writeTable(out io.Writer, name string, glyphs []glyph, opts *options) {
+	fmt.Fprintf(out, "const uint8_t %s [] PROGMEM%s =\n{\n\n", name, opts.alignAttr())
+	writeGlyphData(out, glyphs, opts, "  ")
+	fmt.Fprintf(out, `};`)
+}
+
+// writeStruct writes the sFONT struct name for the array table.
+func writeStruct(out io.Writer, name, table string, width, height int, opts *options) {
+	fmt.Fprintf(out, `sFONT %s = {
+  %s,%s
+  %d,%s
+  %d,%s
+};
+`, name, table, opts.sentinelComment(), width, opts.trailingComment("Width"), height, opts.trailingComment("Height"))
+}
+
+// writeCodepoints writes the table of the rendered codepoints. It is needed
+// to find a glyph if the runes are not the contiguous ASCII range: glyph i
+// starts at i * Height * bytes per row.
+func writeCodepoints(out io.Writer, runes []rune) {
+	fmt.Fprintf(out, "\n\nconst uint32_t FontCustom_Codepoints [] PROGMEM =\n{\n")
+	for i, v := range runes {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		fmt.Fprintf(out, " 0x%.4X,", v)
+	}
+	fmt.Fprintf(out, "\n};\n\nconst uint16_t FontCustom_Count = %d;", len(runes))
+}
+
+// writeGlyphData writes the bytes of all glyphs as the body of a C array
+// initializer, every line prefixed with indent.
+func writeGlyphData(out io.Writer, glyphs []glyph, opts *options, indent string) {
+	size := 0
+	for _, g := range glyphs {
+		writeGlyph(out, &g, opts, indent)
+		size += len(g.storedData())
+	}
+	if len(opts.sentinel) > 0 {
+		if c := opts.comment("sentinel"); c != "" {
+			fmt.Fprintf(out, "%s%s\n", indent, c)
+		}
+		fmt.Fprint(out, indent)
+		for _, o := range opts.sentinel {
+			fmt.Fprintf(out, "0x%.2X, ", o)
+		}
+		fmt.Fprintln(out)
+		size += len(opts.sentinel)
+	}
+	if opts.Align > 0 && size%opts.Align != 0 {
+		if c := opts.comment(fmt.Sprintf("padding to a multiple of %d bytes", opts.Align)); c != "" {
+			fmt.Fprintf(out, "%s%s\n", indent, c)
+		}
+		fmt.Fprint(out, indent)
+		for ; size%opts.Align != 0; size++ {
+			fmt.Fprintf(out, "0x00, ")
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// writeGlyph writes the bytes of a glyph as part of a C array initializer.
+func writeGlyph(out io.Writer, g *glyph, opts *options, indent string) {
+	if g.scheme != schemeRaw {
+		writeEncodedGlyph(out, g, opts, indent)
+		return
+	}
+	rowBytes := g.rowBytes()
+	if c := opts.comment(glyphComment(g)); c != "" {
+		fmt.Fprintf(out, "%s%s\n", indent, c)
+	}
+	for y, tmp := range g.art {
+		fmt.Fprint(out, indent)
+		for _, o := range g.data[y*rowBytes : (y+1)*rowBytes] {
+			fmt.Fprintf(out, "0x%.2X, ", o)
+		}
+		if c := opts.comment(tmp); c != "" {
+			fmt.Fprintf(out, " %s", c)
+		}
+		fmt.Fprintln(out)
+	}
+	if pad := g.data[len(g.art)*rowBytes:]; len(pad) > 0 {
+		if c := opts.comment("padding to the stride"); c != "" {
+			fmt.Fprintf(out, "%s%s\n", indent, c)
+		}
+		writeBytes(out, pad, indent)
+	}
+}
+
+// writeBytes writes data as part of a C array initializer, 16 bytes per
+// line.
+func writeBytes(out io.Writer, data []byte, indent string) {
+	for i, o := range data {
+		if i%16 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, indent)
+		}
+		fmt.Fprintf(out, "0x%.2X, ", o)
+	}
+	fmt.Fprintln(out)
+}
+
+// glyphComment returns the rune and codepoint of g, noting its subpixel
+// phase and if it is blank.
+func glyphComment(g *glyph) string {
+	text := fmt.Sprintf("%c %d", g.rune, g.rune)
+	if g.phases > 0 {
+		text += fmt.Sprintf(" phase %d/%d", g.phase, g.phases)
+	}
+	if g.isBlank() {
+		text += " blank"
+	}
+	return text
+}
+
+// writeSeparateGlyphs writes every glyph as its own array followed by a
+// table pointing to all of them, so unreferenced glyphs can be removed by
+// the linker.
+func writeSeparateGlyphs(out io.Writer, glyphs []glyph, opts *options) {
+	for _, g := range glyphs {
+		fmt.Fprintf(out, "static const uint8_t %s [] PROGMEM%s =\n{\n", glyphName(g.rune), opts.alignAttr())
+		writeGlyph(out, &g, opts, "  ")
+		fmt.Fprint(out, "};\n\n")
+	}
+	fmt.Fprint(out, "const uint8_t *const FontCustom_Glyphs [] PROGMEM =\n{\n")
+	for _, g := range glyphs {
+		fmt.Fprintf(out, "  %s,\n", glyphName(g.rune))
+	}
+	fmt.Fprint(out, "};")
+}
+
+// glyphName returns the name of the array of v with separate glyphs.
+func glyphName(v rune) string {
+	return fmt.Sprintf("FontCustom_glyph_%04X", v)
+}
+
+// writeEncodedGlyph writes an encoded glyph: the ASCII art as comments
+// followed by the stored bytes.
+func writeEncodedGlyph(out io.Writer, g *glyph, opts *options, indent string) {
+	if c := opts.comment(glyphComment(g) + " " + schemeNames[g.scheme]); c != "" {
+		fmt.Fprintf(out, "%s%s\n", indent, c)
+		for _, tmp := range g.art {
+			fmt.Fprintf(out, "%s%s\n", indent, opts.comment(tmp))
+		}
+	}
+	writeBytes(out, g.stored, indent)
+}
+
+// sentinelComment documents the sentinel at the end of the table.
+func (o *options) sentinelComment() string {
+	if len(o.sentinel) == 0 {
+		return ""
+	}
+	return o.trailingComment(fmt.Sprintf("the glyphs are followed by the sentinel 0x%X", o.sentinel))
+}
+
+// alignAttr returns the alignment attribute of the table prefixed by a space.
+func (o *options) alignAttr() string {
+	if o.Align == 0 {
+		return ""
+	}
+	return " " + fmt.Sprintf(o.AlignAttr, o.Align)
+}
+
+// checkAlignAttr returns an error unless the alignment attribute s has
+// exactly one %d and no other verbs, %% is a literal percent sign.
+func checkAlignAttr(s string) error {
+	rest := strings.ReplaceAll(s, "%%", "")
+	if strings.Count(rest, "%d") != 1 || strings.Count(rest, "%") != 1 {
+		return fmt.Errorf("align-attr %q must contain %%d exactly once and no other verb", s)
+	}
+	return nil
+}
+
+// indexColumn is a field of the glyph index.
+type indexColumn struct {
+	typ, name, doc string
+	value          func(g *glyph, offset int) int
+}
+
+// indexColumns returns the fields of the glyph index: the offset of every
+// glyph, its advance with a layout, its size with a rotate map, its
+// stored rows with fixed-width-var-height and its scheme with auto-compress.
+func indexColumns(glyphs []glyph, opts *options) []indexColumn {
+	cols := []indexColumn{{"uint32_t", "offset", "in FontCustom_Table", func(g *glyph, offset int) int { return offset }}}
+	if opts.Layout != "" {
+		typ := "uint8_t"
+		for _, g := range glyphs {
+			if g.advance > 255 || g.advance < 0 {
+				typ = "int16_t"
+			}
+		}
+		cols = append(cols, indexColumn{typ, "advance", "in pixels", func(g *glyph, offset int) int { return g.advance }})
+	}
+	if opts.rotations != nil {
+		var dims []int
+		for _, g := range glyphs {
+			dims = append(dims, g.width, g.height)
+		}
+		typ := uintType(dims)
+		cols = append(cols,
+			indexColumn{typ, "width", "in pixels, swapped with the height by a rotation of 90 or 270 degrees", func(g *glyph, offset int) int { return g.width }},
+			indexColumn{typ, "height", "in rows", func(g *glyph, offset int) int { return g.height }})
+	}
+	if opts.FixedWidthVarHeight {
+		_, height := opts.storedCell()
+		typ := uintType([]int{height})
+		cols = append(cols,
+			indexColumn{typ, "yOffset", "first stored row in the cell", func(g *glyph, offset int) int { top, _ := inkRows(g); return top }},
+			indexColumn{typ, "height", "stored rows, 0 for a blank glyph", func(g *glyph, offset int) int { _, h := inkRows(g); return h }})
+	}
+	if opts.AutoCompress {
+		scheme, _ := rleScheme(opts.RLEUnit)
+		cols = append(cols, indexColumn{"uint8_t", "scheme", fmt.Sprintf("0: raw, %d: %s", scheme, schemeNames[scheme]), func(g *glyph, offset int) int { return int(g.scheme) }})
+	}
+	return cols
+}
+
+// writeIndex writes the index of the glyphs, either as an array of
+// FontCustom_GlyphInfo structs or with the soa layout as one array per
+// field, so all advances can be read in one pass. With index-delta the
+// offsets are delta-coded and followed by their decoder.
+func writeIndex(out io.Writer, glyphs []glyph, opts *options) {
+	cols := indexColumns(glyphs, opts)
+	values := make([][]int, len(glyphs))
+	offset := 0
+	for i, g := range glyphs {
+		for _, c := range cols {
+			values[i] = append(values[i], c.value(&g, offset))
+		}
+		offset += len(g.storedData())
+	}
+	if opts.IndexDelta {
+		offsets := make([]int, len(values))
+		for i, row := range values {
+			offsets[i] = row[0]
+		}
+		d := deltas(offsets)
+		for i := range values {
+			values[i][0] = d[i]
+		}
+		cols[0].typ, cols[0].doc = uintType(d), "difference to the offset of the previous glyph"
+		defer func() {
+			element := "FontCustom_Index[j].offset"
+			if opts.Layout == "soa" {
+				element = "FontCustom_GlyphOffset[j]"
+			}
+			writeDeltaDecoder(out, "FontCustom_IndexOffset", element, cols[0].typ, opts)
+		}()
+	}
+	if opts.Layout == "soa" {
+		for j, c := range cols {
+			fmt.Fprintf(out, "\n\nconst %s FontCustom_Glyph%s%s [] PROGMEM =\n{\n", c.typ, strings.ToUpper(c.name[:1]), c.name[1:])
+			for i := range glyphs {
+				if i%8 == 0 {
+					if i > 0 {
+						fmt.Fprintln(out)
+					}
+					fmt.Fprint(out, " ")
+				}
+				fmt.Fprintf(out, " %d,", values[i][j])
+			}
+			fmt.Fprintf(out, "\n};%s", opts.trailingComment(c.doc))
+		}
+		return
+	}
+	fmt.Fprint(out, "\n\ntypedef struct {\n")
+	for _, c := range cols {
+		fmt.Fprintf(out, "  %s %s;%s\n", c.typ, c.name, opts.trailingComment(c.doc))
+	}
+	fmt.Fprint(out, "} FontCustom_GlyphInfo;\n\nconst FontCustom_GlyphInfo FontCustom_Index [] PROGMEM =\n{\n")
+	for _, row := range values {
+		fields := make([]string, len(row))
+		for j, v := range row {
+			fields[j] = strconv.Itoa(v)
+		}
+		fmt.Fprintf(out, "  {%s},\n", strings.Join(fields, ", "))
+	}
+	fmt.Fprint(out, "};")
+}
+
+// writeOffsets writes the offset of every glyph in the table followed by
+// the total size, so glyph i occupies the bytes from offset i up to but
+// excluding offset i+1. With index-delta they are delta-coded and followed
+// by their decoder.
+func writeOffsets(out io.Writer, glyphs []glyph, opts *options) {
+	fmt.Fprint(out, "\n\n")
+	if c := opts.blockComment("glyph i occupies FontCustom_Table[FontCustom_Offsets[i]] up to FontCustom_Table[FontCustom_Offsets[i + 1] - 1]"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	offsets := []int{0}
+	for _, g := range glyphs {
+		offsets = append(offsets, offsets[len(offsets)-1]+len(g.storedData()))
+	}
+	typ := "uint32_t"
+	if opts.IndexDelta {
+		offsets = deltas(offsets)
+		typ = uintType(offsets)
+	}
+	fmt.Fprintf(out, "const %s FontCustom_Offsets [] PROGMEM =\n{\n", typ)
+	for i, o := range offsets[:len(offsets)-1] {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		fmt.Fprintf(out, " %d,", o)
+	}
+	fmt.Fprintf(out, "\n  %d,\n};", offsets[len(offsets)-1])
+	if opts.IndexDelta {
+		writeDeltaDecoder(out, "FontCustom_Offset", "FontCustom_Offsets[j]", typ, opts)
+	}
+}
+
+// glyphRunes returns the rune of every glyph.
+func glyphRunes(glyphs []glyph) []rune {
+	runes := make([]rune, 0, len(glyphs))
+	for _, g := range glyphs {
+		if g.phase == 0 {
+			runes = append(runes, g.rune)
+		}
+	}
+	return runes
+}
+
+// comment formats text as a comment in the configured style. It returns an
+// empty string if comments are disabled.
+func (o *options) comment(text string) string {
+	switch o.CommentStyle {
+	case "block":
+		return "/* " + text + " */"
+	case "none":
+		return ""
+	}
+	return "// " + text
+}
+
+// blockComment formats text as a block comment unless comments are disabled.
+func (o *options) blockComment(text string) string {
+	if o.CommentStyle == "none" {
+		return ""
+	}
+	return "/* " + text + " */"
+}
+
+// trailingComment returns text as a block comment following a value.
+func (o *options) trailingComment(text string) string {
+	if c := o.blockComment(text); c != "" {
+		return " " + c
+	}
+	return ""
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// overshootRefs returns the cap height and x-height of the font in pixels.
+// A height is 0 if the font does not report it.
+func overshootRefs(f *sfnt.Font, opts *options) (capHeight, xHeight float32, err error) {
+	m, err := f.Metrics(nil, fixed.I(opts.PPEM), font.HintingNone)
+	if err != nil {
+		return 0, 0, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	return float32(m.CapHeight) / 64, float32(m.XHeight) / 64, nil
+}
+
+// trimOvershoot removes the coverage of v above its reference height and
+// below the baseline if the ink exceeds them by no more than the overshoot
+// trim, so that round glyphs end on the same lines as flat ones. Ink which
+// exceeds them further is an ascender or descender and kept.
+func (r *renderer) trimOvershoot(v rune, bounds fixed.Rectangle26_6, originY float32) {
+	if !unicode.IsLetter(v) && !unicode.IsDigit(v) {
+		return
+	}
+	trim := float32(r.opts.OvershootTrim)
+	ref := r.capHeight
+	if unicode.IsLower(v) {
+		ref = r.xHeight
+	}
+	if top := originY - ref; ref > 0 {
+		if over := top - (originY + float32(bounds.Min.Y)/64); over > 0 && over <= trim {
+			// a flat glyph covers the line containing top only partially
+			for y := 0; y < r.height && float32(y) < top; y++ {
+				r.limitRow(y, float32(y)+1-top)
+			}
+		}
+	}
+	if over := float32(bounds.Max.Y) / 64; over > 0 && over <= trim {
+		for y := r.height - 1; y >= 0 && float32(y)+1 > originY; y-- {
+			r.limitRow(y, originY-float32(y))
+		}
+	}
+}
+
+// limitRow caps the coverage of row y to the fraction f of full coverage.
+func (r *renderer) limitRow(y int, f float32) {
+	limit := uint8(math.Round(float64(max(0, min(f, 1))) * 255))
+	row := r.dst.Pix[y*r.dst.Stride : y*r.dst.Stride+r.width]
+	for i, a := range row {
+		row[i] = min(a, limit)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+)
+
+// renderParam is a field of the render parameters block.
+type renderParam struct {
+	typ, name, doc string
+	value          int
+}
+
+// renderParams returns the parameters the glyphs were quantized with, so
+// firmware can blend them accordingly.
+func renderParams(opts *options) []renderParam {
+	threshold, low, high, dither, gamma := 64, 0, 255, 0, 100
+	if g := opts.gradient; g != nil {
+		threshold = g[1]
+	}
+	if opts.Bpp == 4 {
+		threshold = 0 // the coverage is quantized to the upper 4 bits
+	}
+	if c := opts.contrast; c != nil {
+		low, high = c[0], c[1]
+	}
+	if opts.SoftEdges {
+		dither = 1
+	}
+	if opts.SmallSizeBoost && opts.PPEM < opts.SmallSizePPEM {
+		gamma = int(math.Round(100 * opts.SmallSizeGamma))
+	}
+	return []renderParam{
+		{"uint8_t", "bpp", "bits per pixel", opts.Bpp},
+		{"uint8_t", "levels", "gray levels including blank", 1 << opts.Bpp},
+		{"uint8_t", "threshold", "coverage 0-255 at which a pixel is set, at the center with threshold-gradient, 0 with gray levels", threshold},
+		{"uint16_t", "gamma_x100", "gamma times 100 applied to the coverage, 100 if it is linear", gamma},
+		{"uint8_t", "contrast_low", "coverage mapped to 0 before quantizing", low},
+		{"uint8_t", "contrast_high", "coverage mapped to 255 before quantizing", high},
+		{"uint8_t", "dither", "1 if the edge band is stippled", dither},
+	}
+}
+
+// writeRenderParams writes the render parameters as the struct
+// FontCustom_RenderParams.
+func writeRenderParams(out io.Writer, opts *options) {
+	params := renderParams(opts)
+	fmt.Fprint(out, "\n\ntypedef struct {\n")
+	for _, p := range params {
+		fmt.Fprintf(out, "  %s %s;%s\n", p.typ, p.name, opts.trailingComment(p.doc))
+	}
+	fmt.Fprint(out, "} FontCustom_RenderParamsInfo;\n\nconst FontCustom_RenderParamsInfo FontCustom_RenderParams =\n{")
+	for i, p := range params {
+		if i > 0 {
+			fmt.Fprint(out, ",")
+		}
+		fmt.Fprintf(out, " %d", p.value)
+	}
+	fmt.Fprint(out, " };")
+}
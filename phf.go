@@ -0,0 +1,171 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The minimal perfect hash uses hash and displace: every key falls into a
+// bucket by phfHash(key, 0), and every bucket gets a seed which moves all
+// of its keys by phfHash(key, seed) into free slots. The n keys fill
+// exactly n slots, so a lookup needs two hashes and one comparison.
+
+var errPHF = errors.New("could not find a perfect hash function")
+
+// phfHash is a 32 bit integer mix of k with seed.
+func phfHash(k, seed uint32) uint32 {
+	k ^= seed * 0x9E3779B9
+	k ^= k >> 16
+	k *= 0x85EBCA6B
+	k ^= k >> 13
+	k *= 0xC2B2AE35
+	k ^= k >> 16
+	return k
+}
+
+// phf is a minimal perfect hash function over a set of codepoints.
+type phf struct {
+	seeds []uint16 // per bucket
+	slots []int    // index of the key in every slot
+}
+
+// buildPHF finds a minimal perfect hash function for keys.
+func buildPHF(keys []rune) (*phf, error) {
+	n := uint32(len(keys))
+	if n == 0 {
+		return &phf{}, nil
+	}
+	if n > 0xFFFF {
+		return nil, fmt.Errorf("%v: more than 65535 codepoints", errPHF)
+	}
+	for r := (n + 3) / 4; r <= n; r *= 2 {
+		if p, ok := tryPHF(keys, r); ok {
+			return p, nil
+		}
+	}
+	return nil, errPHF
+}
+
+func tryPHF(keys []rune, r uint32) (*phf, bool) {
+	n := uint32(len(keys))
+	buckets := make([][]int, r)
+	for i, k := range keys {
+		b := phfHash(uint32(k), 0) % r
+		buckets[b] = append(buckets[b], i)
+	}
+	order := make([]int, r)
+	for i := range order {
+		order[i] = i
+	}
+	// place the largest buckets first while there are many free slots
+	sort.SliceStable(order, func(i, j int) bool { return len(buckets[order[i]]) > len(buckets[order[j]]) })
+
+	p := &phf{seeds: make([]uint16, r), slots: make([]int, n)}
+	used := make([]bool, n)
+	for _, b := range order {
+		if len(buckets[b]) == 0 {
+			continue
+		}
+		placed := false
+		for seed := uint32(1); seed <= 0xFFFF && !placed; seed++ {
+			var taken []uint32
+			placed = true
+			for _, i := range buckets[b] {
+				s := phfHash(uint32(keys[i]), seed) % n
+				if used[s] {
+					placed = false
+					break
+				}
+				used[s] = true
+				taken = append(taken, s)
+			}
+			if !placed {
+				for _, s := range taken {
+					used[s] = false
+				}
+				continue
+			}
+			p.seeds[b] = uint16(seed)
+			for j, i := range buckets[b] {
+				p.slots[taken[j]] = i
+			}
+		}
+		if !placed {
+			return nil, false
+		}
+	}
+	return p, true
+}
+
+// writePHF writes the hash tables and the C lookup function.
+func writePHF(out io.Writer, runes []rune, opts *options) error {
+	p, err := buildPHF(runes)
+	if err != nil {
+		return err
+	}
+	fmt.Fprint(out, "\n\n")
+	writePgmRead(out, "uint16_t")
+	writePgmRead(out, "uint32_t")
+	fmt.Fprintln(out)
+	writeUint16s(out, "FontCustom_PhfSeeds", p.seeds)
+	if c := opts.blockComment("codepoint and glyph index of every slot"); c != "" {
+		fmt.Fprintf(out, "\n\n%s", c)
+	}
+	fmt.Fprint(out, "\nconst uint32_t FontCustom_PhfKeys [] PROGMEM =\n{\n")
+	for i, slot := range p.slots {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		fmt.Fprintf(out, " 0x%.4X,", runes[slot])
+	}
+	fmt.Fprint(out, "\n};\n\n")
+	index := make([]uint16, len(p.slots))
+	for i, slot := range p.slots {
+		index[i] = uint16(slot)
+	}
+	writeUint16s(out, "FontCustom_PhfIndex", index)
+	fmt.Fprint(out, "\n\n")
+	if c := opts.blockComment("FontCustom_Lookup returns the index of the glyph of c, or -1 if there is none"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, `static inline uint32_t FontCustom_Hash(uint32_t k, uint32_t seed)
+{
+  k ^= seed * 0x9E3779B9u;
+  k ^= k >> 16;
+  k *= 0x85EBCA6Bu;
+  k ^= k >> 13;
+  k *= 0xC2B2AE35u;
+  k ^= k >> 16;
+  return k;
+}
+
+static inline int32_t FontCustom_Lookup(uint32_t c)
+{
+  uint32_t seed = pgm_read_word(&FontCustom_PhfSeeds[FontCustom_Hash(c, 0) %% %du]);
+  uint32_t slot = FontCustom_Hash(c, seed) %% %du;
+  if (pgm_read_dword(&FontCustom_PhfKeys[slot]) != c)
+    return -1;
+  return pgm_read_word(&FontCustom_PhfIndex[slot]);
+}`, len(p.seeds), len(runes))
+	return nil
+}
+
+// writeUint16s writes values as the uint16_t array name.
+func writeUint16s(out io.Writer, name string, values []uint16) {
+	fmt.Fprintf(out, "const uint16_t %s [] PROGMEM =\n{\n", name)
+	for i, v := range values {
+		if i%8 == 0 {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			fmt.Fprint(out, " ")
+		}
+		fmt.Fprintf(out, " %d,", v)
+	}
+	fmt.Fprint(out, "\n};")
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// stage is a transform of the pipeline with its parameter.
+type stage struct {
+	name  string
+	value float64
+}
+
+// stageLimits holds the valid parameters of every transform.
+var stageLimits = map[string][2]float64{
+	"shear": {-45, 45}, // degrees, positive slants to the right
+	"bold":  {1, 8},    // pixels
+	"scale": {0.1, 8},  // factor
+}
+
+// parsePipeline parses transforms like shear=12,bold=1,scale=2.
+func parsePipeline(s string) ([]stage, error) {
+	var stages []stage
+	for _, spec := range strings.Split(s, ",") {
+		name, param, ok := strings.Cut(strings.TrimSpace(spec), "=")
+		limits, known := stageLimits[name]
+		if !ok || !known {
+			return nil, fmt.Errorf("invalid pipeline stage %q, want shear=DEGREES, bold=PIXELS or scale=FACTOR", spec)
+		}
+		v, err := strconv.ParseFloat(param, 64)
+		if err != nil || v < limits[0] || v > limits[1] {
+			return nil, fmt.Errorf("parameter of pipeline stage %q must be a number from %g to %g", spec, limits[0], limits[1])
+		}
+		if name == "bold" && v != math.Trunc(v) {
+			return nil, fmt.Errorf("parameter of pipeline stage %q must be a whole number of pixels", spec)
+		}
+		stages = append(stages, stage{name, v})
+	}
+	return stages, nil
+}
+
+// transform applies the pipeline stages in order to the coverage of img.
+// Shear and scale keep the pen position on the baseline in place.
+func (r *renderer) transform(img *image.Alpha) {
+	if r.tmp == nil {
+		r.tmp = image.NewAlpha(img.Rect)
+	}
+	ox, oy := float64(r.opts.Xoffset), float64(r.originY)
+	for _, s := range r.opts.pipeline {
+		src, dst := r.tmp, img
+		copy(src.Pix, img.Pix)
+		switch s.name {
+		case "shear":
+			t := math.Tan(s.value * math.Pi / 180)
+			for y := 0; y < r.height; y++ {
+				shift := (oy - float64(y) - 0.5) * t
+				for x := 0; x < r.width; x++ {
+					dst.Pix[y*dst.Stride+x] = sample(src, float64(x)-shift, float64(y))
+				}
+			}
+		case "bold":
+			n := int(s.value)
+			for y := 0; y < r.height; y++ {
+				for x := 0; x < r.width; x++ {
+					a := uint8(0)
+					for k := 0; k <= n && k <= x; k++ {
+						a = max(a, src.Pix[y*src.Stride+x-k])
+					}
+					dst.Pix[y*dst.Stride+x] = a
+				}
+			}
+		case "scale":
+			for y := 0; y < r.height; y++ {
+				for x := 0; x < r.width; x++ {
+					sx := ox + (float64(x)+0.5-ox)/s.value - 0.5
+					sy := oy + (float64(y)+0.5-oy)/s.value - 0.5
+					dst.Pix[y*dst.Stride+x] = sample(src, sx, sy)
+				}
+			}
+		}
+	}
+}
+
+// sample returns the bilinearly interpolated coverage of img at x,y, where
+// integer coordinates are pixel centers. Outside of img it is 0.
+func sample(img *image.Alpha, x, y float64) uint8 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := x-x0, y-y0
+	at := func(x, y int) float64 {
+		if !(image.Point{x, y}.In(img.Rect)) {
+			return 0
+		}
+		return float64(img.Pix[y*img.Stride+x])
+	}
+	ix, iy := int(x0), int(y0)
+	top := at(ix, iy)*(1-fx) + at(ix+1, iy)*fx
+	bottom := at(ix, iy+1)*(1-fx) + at(ix+1, iy+1)*fx
+	return uint8(math.Round(top*(1-fy) + bottom*fy))
+}
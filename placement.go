@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// clipWarn is the share of the ink of a glyph which may be clipped without
+// a warning.
+const clipWarn = 0.1
+
+// inkColumns returns the first and the last column of g with a set pixel,
+// or ok false if g is blank.
+func (g *glyph) inkColumns() (first, last int, ok bool) {
+	first, last = g.width, -1
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if g.level(x, y) != 0 {
+				first, last = min(first, x), max(last, x)
+			}
+		}
+	}
+	return first, last, last >= 0
+}
+
+// alignInk moves the ink of g flush to the left or right edge of the cell
+// or centers it, so narrow glyphs sit where the layout expects them.
+func (g *glyph) alignInk(mode string) {
+	first, last, ok := g.inkColumns()
+	if !ok {
+		return
+	}
+	var dx int
+	switch mode {
+	case "left":
+		dx = -first
+	case "right":
+		dx = g.width - 1 - last
+	case "center":
+		dx = (g.width-(last-first+1))/2 - first
+	}
+	if dx != 0 {
+		g.shift(dx)
+	}
+}
+
+// shift moves the pixels of g dx columns to the right, or to the left if
+// dx is negative. Pixels moved out of the cell are lost.
+func (g *glyph) shift(dx int) {
+	rowBytes := g.rowBytes()
+	data := make([]byte, len(g.data))
+	copy(data[g.height*rowBytes:], g.data[g.height*rowBytes:])
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			sx := x - dx
+			if sx < 0 || sx >= g.width {
+				continue
+			}
+			putLevel(data[y*rowBytes:], x, g.level(sx, y), g.bpp, g.lowNibbleFirst)
+		}
+	}
+	g.data = data
+	for y, row := range g.art {
+		if dx > 0 {
+			g.art[y] = strings.Repeat(".", dx) + row[:len(row)-dx]
+		} else {
+			g.art[y] = row[-dx:] + strings.Repeat(".", -dx)
+		}
+	}
+}
+
+// putLevel sets pixel x of the packed row to level l, which must be blank.
+func putLevel(row []byte, x, l, bpp int, lowNibbleFirst bool) {
+	if bpp == 4 {
+		if (x%2 == 0) != lowNibbleFirst {
+			l <<= 4
+		}
+		row[x/2] |= byte(l)
+	} else if l != 0 {
+		row[x/8] |= 0x80 >> (x % 8)
+	}
+}
+
+// parseClip parses a clip rectangle given as x,y,w,h.
+func parseClip(s string) (image.Rectangle, error) {
+	fields := strings.Split(s, ",")
+	var n [4]int
+	if len(fields) != len(n) {
+		return image.Rectangle{}, fmt.Errorf("invalid clip %q, want x,y,w,h", s)
+	}
+	for i, field := range fields {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || v < 0 || (i >= 2 && v == 0) {
+			return image.Rectangle{}, fmt.Errorf("invalid clip %q, want x,y,w,h with a positive size", s)
+		}
+		n[i] = v
+	}
+	return image.Rect(n[0], n[1], n[0]+n[2], n[1]+n[3]), nil
+}
+
+// clip clears the pixels of g outside of rect and returns the share of the
+// ink which is removed, weighted by level.
+func (g *glyph) clip(rect image.Rectangle) float64 {
+	rowBytes := g.rowBytes()
+	data := make([]byte, len(g.data))
+	copy(data[g.height*rowBytes:], g.data[g.height*rowBytes:])
+	total, lost := 0, 0
+	for y := 0; y < g.height; y++ {
+		art := []byte(g.art[y])
+		for x := 0; x < g.width; x++ {
+			l := g.level(x, y)
+			total += l
+			if !image.Pt(x, y).In(rect) {
+				lost += l
+				art[x] = '.'
+				continue
+			}
+			putLevel(data[y*rowBytes:], x, l, g.bpp, g.lowNibbleFirst)
+		}
+		g.art[y] = string(art)
+	}
+	g.data = data
+	if total == 0 {
+		return 0
+	}
+	return float64(lost) / float64(total)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parseRuneSet parses codepoints and ranges like U+0030-U+0039 into set.
+func parseRuneSet(specs []string, set map[rune]bool) error {
+	for _, spec := range specs {
+		from, to, isRange := strings.Cut(spec, "-")
+		lo, err := parseCodepoint(strings.TrimSpace(from))
+		if err != nil {
+			return err
+		}
+		hi := lo
+		if isRange {
+			if hi, err = parseCodepoint(strings.TrimSpace(to)); err != nil {
+				return err
+			}
+		}
+		if hi < lo {
+			return fmt.Errorf("invalid range %q", spec)
+		}
+		for v := lo; v <= hi; v++ {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+// isRed reports whether v is rendered into the red plane.
+func (o *options) isRed(v rune) bool {
+	return o.redAll || o.red[v]
+}
+
+// planeGlyphs returns the glyphs of the red or the black plane. A glyph is
+// blank in the plane it does not belong to, so both planes share the same
+// layout.
+func planeGlyphs(glyphs []glyph, opts *options, red bool) []glyph {
+	plane := make([]glyph, len(glyphs))
+	for i, g := range glyphs {
+		if opts.isRed(g.rune) != red {
+			g = g.blank()
+		}
+		plane[i] = g
+	}
+	return plane
+}
+
+// blank returns a glyph of the same rune and size without any ink.
+func (g glyph) blank() glyph {
+	b := glyph{rune: g.rune, width: g.width, height: g.height, bpp: g.bpp, lowNibbleFirst: g.lowNibbleFirst}
+	b.data = make([]byte, len(g.data))
+	for range g.art {
+		b.art = append(b.art, strings.Repeat(".", g.width))
+	}
+	return b
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// glyphStride returns the stored size of a glyph of the cell rounded up to
+// the next power of two.
+func glyphStride(opts *options) int {
+	g := glyph{bpp: opts.Bpp}
+	g.width, g.height = opts.storedCell()
+	size := g.rowBytes() * g.height
+	if size <= 1 {
+		return 1
+	}
+	return 1 << bits.Len(uint(size-1))
+}
+
+// padPow2 zero-fills every glyph up to the stride.
+func padPow2(glyphs []glyph, opts *options) {
+	stride := glyphStride(opts)
+	for i := range glyphs {
+		g := &glyphs[i]
+		g.data = append(g.data, make([]byte, stride-len(g.data))...)
+	}
+}
+
+// writePaddingReport writes the stride and the overhead of the padding to w.
+func writePaddingReport(w io.Writer, glyphs []glyph, opts *options) {
+	stride := glyphStride(opts)
+	g := glyph{bpp: opts.Bpp}
+	g.width, g.height = opts.storedCell()
+	pad := stride - g.rowBytes()*g.height
+	fmt.Fprintf(w, "pad-pow2: stride %d bytes (index << %d), %d padding bytes per glyph, %d bytes in total, %.1f%% of the table\n",
+		stride, bits.TrailingZeros(uint(stride)), pad, pad*len(glyphs), 100*float64(pad)/float64(stride))
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+const (
+	previewMargin = 4  // pixels around and between the rows
+	previewLabel  = 48 // width of the size labels
+)
+
+// parseSizes parses a comma separated list of font sizes.
+func parseSizes(s string) ([]int, error) {
+	var sizes []int
+	for _, field := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid size %q in %q", field, s)
+		}
+		sizes = append(sizes, n)
+	}
+	return sizes, nil
+}
+
+// previewRow is the sample rendered at one size.
+type previewRow struct {
+	ppem   int
+	glyphs []glyph
+	// step is the distance of the glyphs: the cell width, or the advance
+	// of every glyph if it is 0.
+	step int
+}
+
+// sizeOptions returns the options to render the sample at ppem: the cell
+// is fitted to the font at that size and the glyphs are placed by their
+// advance.
+func sizeOptions(f *sfnt.Font, opts *options, ppem int) (*options, error) {
+	o := *opts
+	m, err := f.Metrics(nil, fixed.I(ppem), font.HintingFull)
+	if err != nil {
+		return nil, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	o.PPEM, o.TopPad, o.Xoffset = ppem, 0, 0
+	o.Yoffset = m.Ascent.Ceil()
+	o.Height = o.Yoffset + m.Descent.Ceil()
+	o.Width = (2*ppem + 7) / 8
+	o.VMetricAlign, o.RespectBearings, o.origin = false, false, origin{}
+	o.Layout = "aos" // not written, it makes the renderer determine the advances
+	o.SubpixelPhases = 1
+	return &o, nil
+}
+
+// previewRows renders the sample once with the configured cell, or once
+// per size with cells fitted to the sizes.
+func previewRows(f *sfnt.Font, opts *options, sizes []int) ([]previewRow, error) {
+	sample := []rune(opts.Sample)
+	if len(sizes) == 0 {
+		o := *opts
+		o.SubpixelPhases = 1 // the sample is set at whole pixels
+		glyphs, err := renderGlyphs(f, sample, &o)
+		if err != nil {
+			return nil, err
+		}
+		width, _ := opts.cell()
+		return []previewRow{{opts.PPEM, glyphs, width}}, nil
+	}
+	var rows []previewRow
+	for _, ppem := range sizes {
+		o, err := sizeOptions(f, opts, ppem)
+		if err != nil {
+			return nil, err
+		}
+		glyphs, err := renderGlyphs(f, sample, o)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, previewRow{ppem, glyphs, 0})
+	}
+	return rows, nil
+}
+
+// previewImage stacks the rows below each other, every one labeled with
+// its size. Gray levels are drawn as shades.
+func previewImage(rows []previewRow) *image.Gray {
+	width, height := 0, previewMargin
+	for _, row := range rows {
+		w, h := 0, 0
+		for _, g := range row.glyphs {
+			w += row.advance(&g)
+			h = max(h, g.height)
+		}
+		if len(row.glyphs) > 0 {
+			// the last glyph may extend beyond its advance
+			last := row.glyphs[len(row.glyphs)-1]
+			w += max(last.width-row.advance(&last), 0)
+		}
+		width = max(width, w)
+		height += max(h, basicfont.Face7x13.Height) + previewMargin
+	}
+	img := image.NewGray(image.Rect(0, 0, previewLabel+width+previewMargin, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	d := font.Drawer{Dst: img, Src: image.Black, Face: basicfont.Face7x13}
+	y := previewMargin
+	for _, row := range rows {
+		d.Dot = fixed.P(previewMargin, y+basicfont.Face7x13.Ascent)
+		d.DrawString(fmt.Sprintf("%dpx", row.ppem))
+		x, h := previewLabel, 0
+		for _, g := range row.glyphs {
+			drawGlyph(img, &g, x, y)
+			x += row.advance(&g)
+			h = max(h, g.height)
+		}
+		y += max(h, basicfont.Face7x13.Height) + previewMargin
+	}
+	return img
+}
+
+// advance returns the distance from g to the next glyph of the row.
+func (row *previewRow) advance(g *glyph) int {
+	if row.step > 0 {
+		return row.step
+	}
+	return g.advance
+}
+
+// drawGlyph darkens the pixels of g in img with the top left corner at x,y.
+func drawGlyph(img *image.Gray, g *glyph, x, y int) {
+	top := 1<<g.bpp - 1
+	for gy := 0; gy < g.height; gy++ {
+		for gx := 0; gx < g.width; gx++ {
+			l := g.level(gx, gy)
+			if l == 0 {
+				continue
+			}
+			shade := uint8(255 - l*255/top)
+			if c := img.GrayAt(x+gx, y+gy); c.Y > shade {
+				img.SetGray(x+gx, y+gy, color.Gray{Y: shade})
+			}
+		}
+	}
+}
+
+// writePreview renders the sample and writes it as PNG to the preview file.
+func writePreview(f *sfnt.Font, opts *options, sizes []int) error {
+	rows, err := previewRows(f, opts, sizes)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(string(opts.Preview))
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, previewImage(rows)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// previewDiffColumns is the number of glyphs per row of a diff preview.
+const previewDiffColumns = 16
+
+// previewDiffImage draws the glyphs of a and b in a grid: ink of both in
+// black, ink only b has in green and ink only a has in red. Gray levels
+// are drawn as shades.
+func previewDiffImage(a, b *header) *image.RGBA {
+	runes := headerRunes(a, b)
+	width, height := max(a.width, b.width), max(a.height, b.height)
+	rows := (len(runes) + previewDiffColumns - 1) / previewDiffColumns
+	img := image.NewRGBA(image.Rect(0, 0, previewMargin+previewDiffColumns*(width+previewMargin), previewMargin+rows*(height+previewMargin)))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+	for i, v := range runes {
+		ga, gb := a.glyphs[v], b.glyphs[v]
+		ox := previewMargin + i%previewDiffColumns*(width+previewMargin)
+		oy := previewMargin + i/previewDiffColumns*(height+previewMargin)
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				la, lb := shade(&ga, x, y), shade(&gb, x, y)
+				var c color.RGBA
+				switch {
+				case la == 0 && lb == 0:
+					continue
+				case la == lb:
+					c = color.RGBA{255 - la, 255 - la, 255 - la, 255}
+				case lb > la:
+					c = color.RGBA{255 - (lb - la), 255 - (lb-la)/3, 255 - (lb - la), 255}
+				default:
+					c = color.RGBA{255 - (la-lb)/3, 255 - (la - lb), 255 - (la - lb), 255}
+				}
+				img.SetRGBA(ox+x, oy+y, c)
+			}
+		}
+	}
+	return img
+}
+
+// shade returns the level of the pixel at x,y of g scaled to 0-255, 0 if
+// g has no glyph or the pixel is outside of it.
+func shade(g *glyph, x, y int) uint8 {
+	if g.data == nil {
+		return 0
+	}
+	return uint8(levelAt(g, x, y) * 255 / (1<<g.bpp - 1))
+}
+
+// writePreviewDiff writes the diff preview of a and b as PNG to name.
+func writePreviewDiff(name string, a, b *header) error {
+	file, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(file, previewDiffImage(a, b)); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
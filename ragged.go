@@ -0,0 +1,109 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/icza/bitio"
+)
+
+// raggedBits returns the number of bits of the used count of a row.
+func raggedBits(width int) int {
+	return bits.Len(uint(width))
+}
+
+// encodeRagged stores every row of g as the number of bits up to its last
+// set pixel followed by these bits, all rows packed back to back.
+func encodeRagged(g *glyph) []byte {
+	b := &bytes.Buffer{}
+	w := bitio.NewWriter(b)
+	n := uint8(raggedBits(g.width))
+	for y := 0; y < g.height; y++ {
+		used := 0
+		for x := 0; x < g.width; x++ {
+			if g.level(x, y) != 0 {
+				used = x + 1
+			}
+		}
+		w.WriteBits(uint64(used), n)
+		for x := 0; x < used; x++ {
+			w.WriteBits(uint64(g.level(x, y)), 1)
+		}
+	}
+	w.Close()
+	return b.Bytes()
+}
+
+// decodeRagged unpacks rows stored by encodeRagged into packed rows.
+func decodeRagged(data []byte, width, height int) ([]byte, error) {
+	r := bitio.NewReader(bytes.NewReader(data))
+	rowBytes := (width + 7) / 8
+	out := make([]byte, rowBytes*height)
+	n := uint8(raggedBits(width))
+	for y := 0; y < height; y++ {
+		used, err := r.ReadBits(n)
+		if err != nil {
+			return nil, fmt.Errorf("ragged row %d: %v", y, err)
+		}
+		if int(used) > width {
+			return nil, fmt.Errorf("ragged row %d uses %d of %d bits", y, used, width)
+		}
+		for x := 0; x < int(used); x++ {
+			set, err := r.ReadBool()
+			if err != nil {
+				return nil, fmt.Errorf("ragged row %d: %v", y, err)
+			}
+			if set {
+				out[y*rowBytes+x/8] |= 0x80 >> (x % 8)
+			}
+		}
+	}
+	return out, nil
+}
+
+// raggedRows stores every glyph with ragged rows.
+func raggedRows(glyphs []glyph) {
+	for i := range glyphs {
+		g := &glyphs[i]
+		g.stored, g.scheme = encodeRagged(g), schemeRagged
+	}
+}
+
+// writeRaggedDecoder writes the C reference decoder of ragged rows.
+func writeRaggedDecoder(out io.Writer, opts *options) {
+	width, height := opts.storedCell()
+	fmt.Fprint(out, "\n\n")
+	writePgmRead(out, "uint8_t")
+	fmt.Fprintf(out, "\n#define FontCustom_RaggedBits %d\n\n", raggedBits(width))
+	if c := opts.blockComment("FontCustom_DecodeRagged unpacks the ragged rows of a glyph starting at src into packed rows of " +
+		fmt.Sprint((width+7)/8) + " bytes at dst: every row is its used bit count in FontCustom_RaggedBits bits followed by that many pixels, MSB first"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, `static inline uint32_t FontCustom_ReadBits(const uint8_t *src, uint32_t *pos, uint8_t n)
+{
+  uint32_t v = 0;
+  while (n--)
+  {
+    v = v << 1 | ((pgm_read_byte(&src[*pos >> 3]) >> (7 - (*pos & 7))) & 1);
+    (*pos)++;
+  }
+  return v;
+}
+
+static inline void FontCustom_DecodeRagged(const uint8_t *src, uint8_t *dst)
+{
+  uint32_t pos = 0;
+  for (uint16_t i = 0; i < %d; i++)
+    dst[i] = 0;
+  for (uint16_t y = 0; y < %d; y++)
+  {
+    uint8_t *row = dst + y * %d;
+    uint32_t used = FontCustom_ReadBits(src, &pos, FontCustom_RaggedBits);
+    for (uint32_t x = 0; x < used; x++)
+      if (FontCustom_ReadBits(src, &pos, 1))
+        row[x >> 3] |= 0x80 >> (x & 7);
+  }
+}`, (width+7)/8*height, height, (width+7)/8)
+}
@@ -0,0 +1,526 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/draw"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/icza/bitio"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// bayer4 is a 4x4 ordered dither matrix used for the soft edge stipple.
+var bayer4 = [4][4]int{
+	{0, 8, 2, 10},
+	{12, 4, 14, 6},
+	{3, 11, 1, 9},
+	{15, 7, 13, 5},
+}
+
+// ink reports whether a pixel at x,y with coverage a should be set.
+func (o *options) ink(a uint8, x, y int) bool {
+	if o.SoftEdges {
+		switch {
+		case int(a) <= o.SoftLow:
+			return false
+		case int(a) >= o.SoftHigh:
+			return true
+		}
+		// map the pixel to a threshold inside the edge band
+		t := o.SoftLow + (2*bayer4[y%4][x%4]+1)*(o.SoftHigh-o.SoftLow)/32
+		return int(a) > t
+	}
+	return int(a) >= o.threshold(x)
+}
+
+// threshold returns the coverage at which a pixel in column x is set: 64,
+// or with threshold-gradient ramped linearly from the center of the cell
+// to its left and right edges.
+func (o *options) threshold(x int) int {
+	g := o.gradient
+	if g == nil {
+		return 64
+	}
+	width, _ := o.cell()
+	if width < 2 {
+		return g[1]
+	}
+	d := math.Abs(2*float64(x)/float64(width-1) - 1) // 0 at the center, 1 at the edges
+	return int(math.Round(float64(g[1]) + d*float64(g[0]-g[1])))
+}
+
+// parseGradient parses a threshold gradient written as edge,center.
+func parseGradient(s string) ([2]int, error) {
+	var g [2]int
+	edge, center, ok := strings.Cut(s, ",")
+	var err1, err2 error
+	g[0], err1 = strconv.Atoi(strings.TrimSpace(edge))
+	g[1], err2 = strconv.Atoi(strings.TrimSpace(center))
+	if !ok || err1 != nil || err2 != nil || g[0] < 1 || g[0] > 255 || g[1] < 1 || g[1] > 255 {
+		return g, fmt.Errorf("threshold-gradient %q must be edge,center with thresholds from 1 to 255", s)
+	}
+	return g, nil
+}
+
+// glyph is a rendered and packed rune.
+type glyph struct {
+	rune    rune
+	width   int // in pixels
+	height  int // in rows
+	advance int // in pixels, only set if needsAdvance
+	top     int // rows from the top of the stored cell to the baseline
+	source  int // index of the font in the sources, 0 is the primary font
+	// phase of phases is the subpixel position of the glyph, phases is 0
+	// without subpixel-phases.
+	phase, phases int
+	bpp           int // bits per pixel, 1 or 4
+	// lowNibbleFirst is set if with 4 bpp the first pixel of a byte is
+	// its low nibble.
+	lowNibbleFirst bool
+	data           []byte   // packed rows, MSB first
+	art            []string // one line of ASCII art per row
+	// stored is the encoded data written to the table if the scheme is
+	// not schemeRaw.
+	stored []byte
+	scheme byte
+}
+
+// storedData returns the bytes written to the table for the glyph.
+func (g *glyph) storedData() []byte {
+	if g.scheme == schemeRaw {
+		return g.data
+	}
+	return g.stored
+}
+
+// rowBytes returns the number of bytes of a packed row.
+func (g *glyph) rowBytes() int {
+	return (g.width*g.bpp + 7) / 8
+}
+
+// isBlank reports whether no pixel of the glyph is set.
+func (g *glyph) isBlank() bool {
+	for _, b := range g.data {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// level returns the value of the pixel at x,y of the glyph, 0 or 1 with
+// 1 bpp and 0 to 15 with 4 bpp.
+func (g *glyph) level(x, y int) int {
+	if g.bpp == 4 {
+		b := g.data[y*g.rowBytes()+x/2]
+		if (x%2 == 0) != g.lowNibbleFirst {
+			return int(b >> 4)
+		}
+		return int(b & 0x0F)
+	}
+	b := g.data[y*g.rowBytes()+x/8]
+	return int(b>>(7-x%8)) & 1
+}
+
+// renderer rasterizes the runes of a font into a fixed size cell.
+// The rasterizer and the destination image are reused between runes,
+// so a renderer must not be used concurrently.
+type renderer struct {
+	opts   *options
+	font   *sfnt.Font
+	buf    sfnt.Buffer
+	r      *vector.Rasterizer
+	dst    *image.Alpha
+	ss     *image.Alpha // supersampled cell, only used with a scale above 1
+	tmp    *image.Alpha // copy of the cell for the pipeline
+	width  int
+	height int
+	// originY is the row of the baseline.
+	originY float32
+	// figureAdvance is the common advance of the digits with tabular figures.
+	figureAdvance fixed.Int26_6
+	// variants are the glyphs of variation sequences by base codepoint.
+	variants map[rune]sfnt.GlyphIndex
+	// capHeight and xHeight are the reference heights for overshoot-trim.
+	capHeight, xHeight float32
+	// phase is the subpixel shift of the origin in pixels.
+	phase float32
+}
+
+// cell returns the size of a glyph cell in pixels. If the output has no
+// advances the tracking widens or narrows the cell.
+func (o *options) cell() (width, height int) {
+	if !o.needsAdvance() {
+		return o.Width*8 + o.Tracking, o.Height
+	}
+	return o.Width * 8, o.Height
+}
+
+// needsAdvance reports whether the output contains the advance of every glyph.
+func (o *options) needsAdvance() bool {
+	return o.Layout != "" || o.Format == "freetype" || o.Format == "strip"
+}
+
+// track adds the tracking to a non-zero advance, keeping it at least 1.
+func (o *options) track(advance int) int {
+	if advance == 0 || !o.needsAdvance() {
+		return advance
+	}
+	return max(advance+o.Tracking, 1)
+}
+
+// lowNibbleFirst returns 1 if with 4 bpp the first pixel of a byte is its
+// low nibble, 0 otherwise.
+func (o *options) lowNibbleFirst() int {
+	if o.NibbleOrder == "low" {
+		return 1
+	}
+	return 0
+}
+
+// storedCell returns the size of a stored glyph including its padding.
+func (o *options) storedCell() (width, height int) {
+	width, height = o.cell()
+	return width, o.TopPad + height
+}
+
+func newRenderer(f *sfnt.Font, opts *options) (*renderer, error) {
+	width, height := opts.cell()
+	r := &renderer{
+		opts:     opts,
+		font:     f,
+		r:        vector.NewRasterizer(width, height),
+		dst:      image.NewAlpha(image.Rect(0, 0, width, height)),
+		width:    width,
+		height:   height,
+		originY:  float32(opts.Yoffset),
+		variants: opts.variants,
+	}
+	if opts.Scale > 1 {
+		r.ss = image.NewAlpha(image.Rect(0, 0, width*opts.Scale, height*opts.Scale))
+	}
+	if opts.TabularFigures {
+		for v := '0'; v <= '9'; v++ {
+			x, err := f.GlyphIndex(&r.buf, v)
+			if err != nil {
+				return nil, fmt.Errorf("GlyphIndex: %v", err)
+			}
+			if x == 0 {
+				continue
+			}
+			adv, err := f.GlyphAdvance(&r.buf, x, fixed.I(opts.PPEM), font.HintingNone)
+			if err != nil {
+				return nil, fmt.Errorf("GlyphAdvance: %v", err)
+			}
+			r.figureAdvance = max(r.figureAdvance, adv)
+		}
+	}
+	if opts.OvershootTrim > 0 {
+		var err error
+		r.capHeight, r.xHeight, err = overshootRefs(f, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.VMetricAlign {
+		b, err := baseline(f, opts)
+		if err != nil {
+			return nil, err
+		}
+		r.originY = float32(b)
+	}
+	return r, nil
+}
+
+// baseline returns the row of the baseline which centers the font's
+// ascent and descent in the cell. If the cell is too small the descent
+// is clipped.
+func baseline(f *sfnt.Font, opts *options) (int, error) {
+	m, err := f.Metrics(nil, fixed.I(opts.PPEM), font.HintingFull)
+	if err != nil {
+		return 0, fmt.Errorf("could not get font metrics: %v", err)
+	}
+	ascent, descent := m.Ascent.Ceil(), m.Descent.Ceil()
+	b := ascent
+	if room := opts.Height - ascent - descent; room > 0 {
+		b += room / 2
+	}
+	return b, nil
+}
+
+// bearingOffset returns the x origin which places a glyph in a cell of
+// the given width according to its side bearings: the advance is centered
+// in the cell, and if it is too wide the free space is split in the ratio
+// of the bearings.
+func bearingOffset(bounds fixed.Rectangle26_6, advance fixed.Int26_6, width int) float32 {
+	w := float32(width)
+	adv := float32(advance) / 64
+	if adv <= w {
+		return (w - adv) / 2
+	}
+	minX, maxX := float32(bounds.Min.X)/64, float32(bounds.Max.X)/64
+	space := w - (maxX - minX)
+	if space < 0 {
+		// the ink does not fit either, center it
+		return space/2 - minX
+	}
+	lsb, rsb := max(minX, 0), max(adv-maxX, 0)
+	if lsb+rsb == 0 {
+		return space/2 - minX
+	}
+	return space*lsb/(lsb+rsb) - minX
+}
+
+// snap rounds v to the nearest multiple of 1/grid.
+func snap(v float32, grid int) float32 {
+	g := float64(grid)
+	return float32(math.Round(float64(v)*g) / g)
+}
+
+// render rasterizes v into the cell and returns its advance, which is only
+// determined if it is needed. The returned image is only valid until the
+// next call to render.
+func (r *renderer) render(v rune) (*image.Alpha, fixed.Int26_6, error) {
+	x, err := r.font.GlyphIndex(&r.buf, v)
+	if err != nil {
+		return nil, 0, fmt.Errorf("GlyphIndex: %v", err)
+	}
+	if x == 0 {
+		return nil, 0, fmt.Errorf("GlyphIndex: no glyph index found for the rune '%c'", v)
+	}
+	if vx, ok := r.variants[v]; ok {
+		x = vx
+	}
+
+	originX := float32(r.opts.Xoffset) + r.phase
+	originY := r.originY
+
+	var bounds fixed.Rectangle26_6
+	var advance fixed.Int26_6
+	figure := r.opts.TabularFigures && v >= '0' && v <= '9'
+	if r.opts.VMetricAlign || r.opts.RespectBearings || r.opts.OvershootTrim > 0 || r.opts.needsAdvance() || r.opts.origin.x == "right" || r.opts.GridStrict || figure {
+		bounds, advance, err = r.font.GlyphBounds(&r.buf, x, fixed.I(r.opts.PPEM), font.HintingNone)
+		if err != nil {
+			return nil, 0, fmt.Errorf("GlyphBounds: %v", err)
+		}
+	}
+	if figure {
+		// center the digit in the common figure advance and continue as
+		// if the digit had that advance
+		shift := (r.figureAdvance - advance) / 2
+		originX += float32(shift) / 64
+		bounds = bounds.Add(fixed.Point26_6{X: shift})
+		advance = r.figureAdvance
+	}
+	if r.opts.RespectBearings {
+		originX += bearingOffset(bounds, advance, r.width)
+	}
+	if r.opts.origin.x == "right" {
+		originX = float32(r.width-r.opts.origin.xVal) - float32(advance)/64
+	}
+	if r.opts.SnapOrigin {
+		originX, originY = snap(originX, r.opts.SnapGrid), snap(originY, r.opts.SnapGrid)
+	}
+	if r.opts.VMetricAlign {
+		top := originY + float32(bounds.Min.Y)/64
+		bottom := originY + float32(bounds.Max.Y)/64
+		if top < 0 || bottom > float32(r.height) {
+			log.Printf("rune '%c' does not fit into the cell (%.1f to %.1f of %d lines)", v, top, bottom, r.height)
+		}
+	}
+
+	if r.opts.GridStrict && !bounds.Empty() {
+		if err := gridOverflow(v, bounds, originX, originY, r.width, r.height); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	segments, err := r.font.LoadGlyph(&r.buf, x, fixed.I(r.opts.PPEM), nil)
+	if err != nil {
+		return nil, 0, fmt.Errorf("LoadGlyph: %v", err)
+	}
+	if len(segments) == 0 {
+		// blank glyphs like the space have a glyph index but no outline
+		clear(r.dst.Pix)
+		return r.dst, advance, nil
+	}
+	scale := float32(r.opts.Scale)
+	w, h := r.width*r.opts.Scale, r.height*r.opts.Scale
+	r.r.Reset(w, h)
+	r.r.DrawOp = draw.Src // Reset restores draw.Over
+	// pt maps a point of the outline into the (supersampled) cell.
+	// The divisions by 64 below is because the seg.Args values have type
+	// fixed.Int26_6, a 26.6 fixed point number, and 1<<6 == 64.
+	pt := func(p fixed.Point26_6) (float32, float32) {
+		return (originX + float32(p.X)/64) * scale, (originY + float32(p.Y)/64) * scale
+	}
+	for _, seg := range segments {
+		switch seg.Op {
+		case sfnt.SegmentOpMoveTo:
+			r.r.MoveTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpLineTo:
+			r.r.LineTo(pt(seg.Args[0]))
+		case sfnt.SegmentOpQuadTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			r.r.QuadTo(bx, by, cx, cy)
+		case sfnt.SegmentOpCubeTo:
+			bx, by := pt(seg.Args[0])
+			cx, cy := pt(seg.Args[1])
+			dx, dy := pt(seg.Args[2])
+			r.r.CubeTo(bx, by, cx, cy, dx, dy)
+		default:
+			return nil, 0, fmt.Errorf("OP: %v", seg.Op)
+		}
+	}
+	if r.opts.Scale == 1 {
+		r.r.Draw(r.dst, r.dst.Bounds(), image.Opaque, image.Point{})
+	} else {
+		r.r.Draw(r.ss, r.ss.Bounds(), image.Opaque, image.Point{})
+		r.downsample()
+	}
+	if r.opts.OvershootTrim > 0 {
+		r.trimOvershoot(v, bounds, originY)
+	}
+	return r.dst, advance, nil
+}
+
+// packNibbles quantizes the coverage of img to 4 bits and packs two pixels
+// into every byte, the first one into the high nibble unless lowFirst is set.
+// Every row starts with a new byte.
+func packNibbles(img *image.Alpha, lowFirst bool) []byte {
+	b := img.Bounds()
+	rowBytes := (b.Dx() + 1) / 2
+	out := make([]byte, 0, rowBytes*b.Dy())
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := make([]byte, rowBytes)
+		for x := 0; x < b.Dx(); x++ {
+			n := img.AlphaAt(b.Min.X+x, y).A >> 4
+			if (x%2 == 0) != lowFirst {
+				n <<= 4
+			}
+			row[x/2] |= n
+		}
+		out = append(out, row...)
+	}
+	return out
+}
+
+// nibbleArt returns row y of img as ASCII art of its 4 bit levels: a hex
+// digit per pixel and a dot for a blank one.
+func nibbleArt(img *image.Alpha, y int) string {
+	const digits = ".123456789ABCDEF"
+	var s strings.Builder
+	for x := img.Rect.Min.X; x < img.Rect.Max.X; x++ {
+		s.WriteByte(digits[img.AlphaAt(x, y).A>>4])
+	}
+	return s.String()
+}
+
+// parseContrast parses the "low,high" bounds of the contrast stretch.
+func parseContrast(s string) ([2]int, error) {
+	var c [2]int
+	lo, hi, ok := strings.Cut(s, ",")
+	var err1, err2 error
+	c[0], err1 = strconv.Atoi(strings.TrimSpace(lo))
+	c[1], err2 = strconv.Atoi(strings.TrimSpace(hi))
+	if !ok || err1 != nil || err2 != nil || c[0] < 0 || c[1] > 255 || c[0] >= c[1] {
+		return c, fmt.Errorf("contrast %q must be low,high with 0 <= low < high <= 255", s)
+	}
+	return c, nil
+}
+
+// stretch linearly maps the coverage of img so that lo becomes 0 and hi
+// becomes 255.
+func stretch(img *image.Alpha, lo, hi int) {
+	for i, a := range img.Pix {
+		v := (int(a) - lo) * 255 / (hi - lo)
+		img.Pix[i] = uint8(min(max(v, 0), 255))
+	}
+}
+
+// boost raises the coverage of img by the gamma curve a' = a^(1/gamma),
+// which lifts faint pixels more than dense ones.
+func boost(img *image.Alpha, gamma float64) {
+	var curve [256]uint8
+	for a := range curve {
+		curve[a] = uint8(math.Round(255 * math.Pow(float64(a)/255, 1/gamma)))
+	}
+	for i, a := range img.Pix {
+		img.Pix[i] = curve[a]
+	}
+}
+
+// glyph renders v and packs it into rows of bits, or of nibbles with 4 bpp.
+func (r *renderer) glyph(v rune) (glyph, error) {
+	dst, advance, err := r.render(v)
+	if err != nil {
+		return glyph{}, err
+	}
+	if len(r.opts.pipeline) > 0 {
+		r.transform(dst)
+	}
+	if c := r.opts.contrast; c != nil {
+		stretch(dst, c[0], c[1])
+	}
+	if r.opts.SmallSizeBoost && r.opts.PPEM < r.opts.SmallSizePPEM {
+		boost(dst, r.opts.SmallSizeGamma)
+	}
+	g := glyph{rune: v, width: r.width, height: r.opts.TopPad + r.height, advance: r.opts.track(advance.Round()),
+		top: r.opts.TopPad + int(math.Round(float64(r.originY))),
+		bpp: r.opts.Bpp, lowNibbleFirst: r.opts.NibbleOrder == "low"}
+	b := &bytes.Buffer{}
+	for y := 0; y < r.opts.TopPad; y++ {
+		b.Write(make([]byte, g.rowBytes()))
+		g.art = append(g.art, strings.Repeat(".", r.width))
+	}
+	if g.bpp == 4 {
+		g.data = append(b.Bytes(), packNibbles(dst, g.lowNibbleFirst)...)
+		for y := 0; y < r.height; y++ {
+			g.art = append(g.art, nibbleArt(dst, y))
+		}
+		r.finish(&g)
+		return g, nil
+	}
+	for y := 0; y < r.height; y++ {
+		w := bitio.NewWriter(b)
+		tmp := ""
+		for x := 0; x < r.width; x++ {
+			a := dst.AlphaAt(x, y).A
+			if !r.opts.ink(a, x, y) {
+				w.WriteBits(0, 1)
+				tmp += "."
+			} else {
+				w.WriteBits(1, 1)
+				tmp += "#"
+			}
+		}
+		w.Close()
+		g.art = append(g.art, tmp)
+	}
+	g.data = b.Bytes()
+	r.finish(&g)
+	return g, nil
+}
+
+// finish places the ink of the packed glyph g in the cell, clips it and
+// rotates it.
+func (r *renderer) finish(g *glyph) {
+	if r.opts.AlignGlyph != "" {
+		g.alignInk(r.opts.AlignGlyph)
+	}
+	if c := r.opts.clip; c != nil {
+		if lost := g.clip(*c); lost > clipWarn {
+			log.Printf("rune '%c': the clip removes %.0f%% of the ink", g.rune, 100*lost)
+		}
+	}
+	g.rotate(r.opts.rotations[g.rune])
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestNibbleOrderRoundTrip decodes the 4 bpp bitmap of a glyph in both
+// nibble orders and compares it with the quantized coverage.
+func TestNibbleOrderRoundTrip(t *testing.T) {
+	for _, order := range []string{"high", "low"} {
+		t.Run(order, func(t *testing.T) {
+			f, opts := testSetup(t)
+			opts.Bpp, opts.NibbleOrder = 4, order
+			r, err := newRenderer(f, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := r.glyph('@')
+			if err != nil {
+				t.Fatal(err)
+			}
+			img, _, err := r.render('@')
+			if err != nil {
+				t.Fatal(err)
+			}
+			rowBytes := (g.width + 1) / 2
+			if len(g.data) != rowBytes*g.height {
+				t.Fatalf("got %d bytes, want %d", len(g.data), rowBytes*g.height)
+			}
+			for y := 0; y < g.height; y++ {
+				for x := 0; x < g.width; x++ {
+					b := g.data[y*rowBytes+x/2]
+					n := b >> 4
+					if (x%2 == 1) == (order == "high") {
+						n = b & 0x0F
+					}
+					if want := img.AlphaAt(x, y).A >> 4; n != want {
+						t.Fatalf("pixel %d,%d is %d, want %d", x, y, n, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestBlankGlyph renders the space, which has a glyph but no outline, into
+// an all-zero cell of the full size.
+func TestBlankGlyph(t *testing.T) {
+	f, opts := testSetup(t)
+	r, err := newRenderer(f, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// render a glyph with ink first, the cell is reused
+	if _, err := r.glyph('@'); err != nil {
+		t.Fatal(err)
+	}
+	g, err := r.glyph(' ')
+	if err != nil {
+		t.Fatal(err)
+	}
+	width, height := opts.storedCell()
+	if want := (width + 7) / 8 * height; len(g.data) != want || len(g.art) != height {
+		t.Fatalf("got %d bytes and %d rows, want %d bytes and %d rows", len(g.data), len(g.art), want, height)
+	}
+	if !g.isBlank() {
+		t.Fatal("the space is not blank")
+	}
+	var b strings.Builder
+	writeGlyph(&b, &g, opts, "")
+	if !strings.HasPrefix(b.String(), "//   32 blank\n") {
+		t.Fatalf("the comment does not note the blank glyph:\n%s", b.String())
+	}
+}
+
+// TestAlignGlyph decodes a narrow glyph in every alignment and checks that
+// its ink sits at the requested place and is the unaligned ink shifted.
+func TestAlignGlyph(t *testing.T) {
+	f, opts := testSetup(t)
+	r, err := newRenderer(f, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref, err := r.glyph('i')
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, last, _ := ref.inkColumns()
+	inkWidth := last - first + 1
+	for mode, want := range map[string]int{
+		"left":   0,
+		"right":  ref.width - inkWidth,
+		"center": (ref.width - inkWidth) / 2,
+	} {
+		t.Run(mode, func(t *testing.T) {
+			opts.AlignGlyph = mode
+			r, err := newRenderer(f, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			g, err := r.glyph('i')
+			if err != nil {
+				t.Fatal(err)
+			}
+			rowBytes := (g.width + 7) / 8
+			for y := 0; y < g.height; y++ {
+				for x := 0; x < g.width; x++ {
+					set := g.data[y*rowBytes+x/8]&(0x80>>(x%8)) != 0
+					sx := x - want + first
+					had := sx >= 0 && sx < ref.width && ref.level(sx, y) != 0
+					if set != had {
+						t.Fatalf("pixel %d,%d is %v, want %v", x, y, set, had)
+					}
+					if set != (g.art[y][x] == '#') {
+						t.Fatalf("the art of pixel %d,%d does not match the bitmap", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+// TestSoftEdgeBounds checks that with soft edges coverage at or below
+// soft-low is always blank and at or above soft-high is always set, also
+// where the bounds lie on the other side of the plain threshold.
+func TestSoftEdgeBounds(t *testing.T) {
+	_, opts := testSetup(t)
+	opts.SoftEdges = true
+	for _, c := range []struct{ low, high int }{{100, 192}, {10, 50}} {
+		opts.SoftLow, opts.SoftHigh = c.low, c.high
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				if opts.ink(uint8(c.low), x, y) {
+					t.Errorf("soft-low %d: pixel %d,%d at %d is set", c.low, x, y, c.low)
+				}
+				if !opts.ink(uint8(c.high), x, y) {
+					t.Errorf("soft-high %d: pixel %d,%d at %d is blank", c.high, x, y, c.high)
+				}
+			}
+		}
+	}
+}
+
+// TestTrackingAdvance checks that the formats storing advances add the
+// tracking to them and keep the cell.
+func TestTrackingAdvance(t *testing.T) {
+	for _, format := range []string{"strip", "freetype"} {
+		t.Run(format, func(t *testing.T) {
+			f, opts := testSetup(t)
+			opts.Format = format
+			r, err := newRenderer(f, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			plain, err := r.glyph('A')
+			if err != nil {
+				t.Fatal(err)
+			}
+			opts.Tracking = 3
+			if r, err = newRenderer(f, opts); err != nil {
+				t.Fatal(err)
+			}
+			tracked, err := r.glyph('A')
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tracked.advance != plain.advance+3 || tracked.width != plain.width {
+				t.Fatalf("tracked advance %d and width %d, want %d and %d", tracked.advance, tracked.width, plain.advance+3, plain.width)
+			}
+		})
+	}
+}
+
+// TestGridStrictPadPow2 checks that glyphs padded to a power of two stride
+// pass the grid check.
+func TestGridStrictPadPow2(t *testing.T) {
+	f, opts := testSetup(t)
+	opts.GridStrict, opts.PadPow2 = true, true // 48 bytes, padded to 64
+	glyphs, err := renderGlyphs(f, []rune("0123456789"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkGrid(glyphs, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(glyphs[0].storedData()) != 64 {
+		t.Fatalf("the glyphs are stored with %d bytes, want 64", len(glyphs[0].storedData()))
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+)
+
+// writeSizeReport writes the stored size of every glyph to w, largest
+// first, followed by the totals.
+func writeSizeReport(w io.Writer, glyphs []glyph) {
+	sorted := make([]glyph, len(glyphs))
+	copy(sorted, glyphs)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(sorted[i].storedData()) > len(sorted[j].storedData())
+	})
+	total := 0
+	fmt.Fprintln(w, "glyph sizes:")
+	for _, g := range sorted {
+		fmt.Fprintf(w, "  U+%04X %-3s %6d bytes %s\n", g.rune, printable(g.rune), len(g.storedData()), schemeNames[g.scheme])
+		total += len(g.storedData())
+	}
+	fmt.Fprintf(w, "total: %d glyphs, %d bytes", len(glyphs), total)
+	if len(glyphs) > 0 {
+		fmt.Fprintf(w, ", %.1f bytes per glyph", float64(total)/float64(len(glyphs)))
+	}
+	fmt.Fprintln(w)
+	raw, byteRLE, bitRLE := 0, 0, 0
+	for _, g := range glyphs {
+		raw += len(g.data)
+		byteRLE += len(encodeRLE(g.data))
+		bitRLE += len(encodeBitRLE(g.data))
+	}
+	if raw > 0 {
+		fmt.Fprintf(w, "RLE of all glyphs: byte unit %d bytes (%.2f of raw), bit unit %d bytes (%.2f of raw)\n",
+			byteRLE, float64(byteRLE)/float64(raw), bitRLE, float64(bitRLE)/float64(raw))
+	}
+}
+
+// printable returns v quoted if it can be shown in a report.
+func printable(v rune) string {
+	if v < ' ' || v == 0x7F {
+		return ""
+	}
+	return "'" + string(v) + "'"
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// readRotateMap reads a rotate map with a codepoint, written as in a
+// coverage file, and a clockwise angle of 0, 90, 180 or 270 degrees per
+// line. Everything after a # is a comment.
+func readRotateMap(name string) (map[rune]int, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	angles := map[rune]int{}
+	s := bufio.NewScanner(file)
+	for line := 1; s.Scan(); line++ {
+		text, _, _ := strings.Cut(s.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want a codepoint and an angle", name, line)
+		}
+		v, err := parseCodepoint(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+		angle, err := strconv.Atoi(fields[1])
+		if err != nil || angle%90 != 0 || angle < 0 || angle >= 360 {
+			return nil, fmt.Errorf("%s:%d: invalid angle %q, want 0, 90, 180 or 270", name, line, fields[1])
+		}
+		angles[v] = angle
+	}
+	return angles, s.Err()
+}
+
+// rotate turns g clockwise by angle degrees. With 90 and 270 degrees the
+// width and the height of g are swapped.
+func (g *glyph) rotate(angle int) {
+	if angle == 0 {
+		return
+	}
+	src := *g
+	if angle != 180 {
+		g.width, g.height = src.height, src.width
+	}
+	rowBytes := g.rowBytes()
+	g.data = make([]byte, rowBytes*g.height)
+	g.art = nil
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			sx, sy := y, src.height-1-x // 90 degrees
+			switch angle {
+			case 180:
+				sx, sy = src.width-1-x, src.height-1-y
+			case 270:
+				sx, sy = src.width-1-y, x
+			}
+			putLevel(g.data[y*rowBytes:], x, src.level(sx, sy), g.bpp, g.lowNibbleFirst)
+		}
+	}
+	for y := 0; y < g.height; y++ {
+		g.art = append(g.art, artRow(g, y, g.width))
+	}
+}
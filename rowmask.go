@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"io"
+)
+
+// rowMaskBytes returns the number of bytes of the row mask of a glyph.
+func rowMaskBytes(height int) int {
+	return (height + 7) / 8
+}
+
+// encodeRowMask stores g as a mask of its rows with ink, bit 7 of the
+// first byte for row 0, followed by the bytes of these rows only.
+func encodeRowMask(g *glyph) []byte {
+	rowBytes := g.rowBytes()
+	out := make([]byte, rowMaskBytes(g.height))
+	for y := 0; y < g.height; y++ {
+		row := g.data[y*rowBytes : (y+1)*rowBytes]
+		for _, b := range row {
+			if b != 0 {
+				out[y/8] |= 0x80 >> (y % 8)
+				out = append(out, row...)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// decodeRowMask unpacks rows stored by encodeRowMask into rows of rowBytes.
+func decodeRowMask(data []byte, rowBytes, height int) ([]byte, error) {
+	n := rowMaskBytes(height)
+	if len(data) < n {
+		return nil, fmt.Errorf("row mask of %d bytes, want %d", len(data), n)
+	}
+	mask, rows := data[:n], data[n:]
+	out := make([]byte, rowBytes*height)
+	for y := 0; y < height; y++ {
+		if mask[y/8]&(0x80>>(y%8)) == 0 {
+			continue
+		}
+		if len(rows) < rowBytes {
+			return nil, fmt.Errorf("row %d is missing", y)
+		}
+		copy(out[y*rowBytes:], rows[:rowBytes])
+		rows = rows[rowBytes:]
+	}
+	return out, nil
+}
+
+// skipEmptyRows stores every glyph with a row mask.
+func skipEmptyRows(glyphs []glyph) {
+	for i := range glyphs {
+		g := &glyphs[i]
+		g.stored, g.scheme = encodeRowMask(g), schemeRowMask
+	}
+}
+
+// writeRowMaskDecoder writes the C reference decoder of glyphs stored with
+// a row mask.
+func writeRowMaskDecoder(out io.Writer, opts *options) {
+	width, height := opts.storedCell()
+	rowBytes := (width*opts.Bpp + 7) / 8
+	fmt.Fprint(out, "\n\n")
+	writePgmRead(out, "uint8_t")
+	fmt.Fprintf(out, "\n#define FontCustom_RowMaskBytes %d\n\n", rowMaskBytes(height))
+	if c := opts.blockComment("FontCustom_DecodeRows unpacks a glyph starting at src into " + fmt.Sprint(height) + " rows of " +
+		fmt.Sprint(rowBytes) + " bytes at dst: bit 7 - y % 8 of mask byte y / 8 is set if row y is stored, the stored rows follow the mask, the others are blank"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, `static inline void FontCustom_DecodeRows(const uint8_t *src, uint8_t *dst)
+{
+  const uint8_t *row = src + FontCustom_RowMaskBytes;
+  for (uint16_t y = 0; y < %d; y++)
+  {
+    uint8_t stored = pgm_read_byte(&src[y >> 3]) & (0x80 >> (y & 7));
+    for (uint16_t i = 0; i < %d; i++)
+      dst[y * %[2]d + i] = stored ? pgm_read_byte(row++) : 0;
+  }
+}`, height, rowBytes)
+}
+
+// inkRows returns the first row of g with ink and the number of rows up to
+// and including the last one, 0 and 0 if g is blank.
+func inkRows(g *glyph) (top, height int) {
+	rowBytes := g.rowBytes()
+	top, last := -1, -1
+	for y := 0; y < g.height; y++ {
+		for _, b := range g.data[y*rowBytes : (y+1)*rowBytes] {
+			if b != 0 {
+				if top < 0 {
+					top = y
+				}
+				last = y
+				break
+			}
+		}
+	}
+	if top < 0 {
+		return 0, 0
+	}
+	return top, last - top + 1
+}
+
+// trimRows stores every glyph with only the rows from its first to its
+// last row with ink.
+func trimRows(glyphs []glyph) {
+	for i := range glyphs {
+		g := &glyphs[i]
+		top, height := inkRows(g)
+		g.stored, g.scheme = g.data[top*g.rowBytes():(top+height)*g.rowBytes()], schemeTrimmed
+	}
+}
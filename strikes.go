@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// The sfnt package only reads outlines, so the embedded bitmap strikes are
+// read here directly from the EBLC and EBDT tables. Only monochrome strikes
+// with index formats 1 and 3 and image formats 1 and 2 are supported. See
+// https://learn.microsoft.com/en-us/typography/opentype/spec/eblc
+
+var errMalformedStrike = errors.New("malformed EBLC or EBDT table")
+
+// strikeBitmap is the bitmap of a glyph in a strike, with its bearings
+// relative to the pen position on the baseline.
+type strikeBitmap struct {
+	width, height      int
+	bearingX, bearingY int
+	data               []byte // rows of width bits, bit-aligned
+}
+
+// at reports whether the pixel at x,y of the bitmap is set.
+func (b *strikeBitmap) at(x, y int) bool {
+	i := y*b.width + x
+	return b.data[i/8]&(0x80>>(i%8)) != 0
+}
+
+// strike maps glyph indexes to their bitmaps at one size.
+type strike map[sfnt.GlyphIndex]*strikeBitmap
+
+// strikePPEMs returns the sizes of the strikes in the EBLC table.
+func strikePPEMs(eblc []byte) []int {
+	var sizes []int
+	n := int(binary.BigEndian.Uint32(eblc[4:]))
+	for i := 0; i < n && 8+48*(i+1) <= len(eblc); i++ {
+		sizes = append(sizes, int(eblc[8+48*i+45]))
+	}
+	return sizes
+}
+
+// readStrike reads the strike of the font data src at ppem.
+func readStrike(src []byte, ppem int) (strike, error) {
+	eblc, ok1 := sfntTable(src, "EBLC")
+	ebdt, ok2 := sfntTable(src, "EBDT")
+	if !ok1 || !ok2 {
+		return nil, errMalformedStrike
+	}
+	if eblc == nil || ebdt == nil {
+		return nil, fmt.Errorf("the font has no EBLC and EBDT bitmap strikes")
+	}
+	if len(eblc) < 8 {
+		return nil, errMalformedStrike
+	}
+	numSizes := int(binary.BigEndian.Uint32(eblc[4:]))
+	for i := 0; i < numSizes; i++ {
+		rec := 8 + 48*i
+		if rec+48 > len(eblc) {
+			return nil, errMalformedStrike
+		}
+		if int(eblc[rec+45]) != ppem {
+			continue
+		}
+		if depth := eblc[rec+46]; depth != 1 {
+			return nil, fmt.Errorf("the strike at %d ppem has %d bits per pixel, only 1 is supported", ppem, depth)
+		}
+		return parseStrike(eblc, ebdt, eblc[rec:rec+48])
+	}
+	return nil, fmt.Errorf("the font has no bitmap strike at %d ppem, only at %v", ppem, strikePPEMs(eblc))
+}
+
+// parseStrike reads the index subtables of the bitmap size record size.
+func parseStrike(eblc, ebdt, size []byte) (strike, error) {
+	s := strike{}
+	array := int(binary.BigEndian.Uint32(size[0:]))
+	n := int(binary.BigEndian.Uint32(size[8:]))
+	for i := 0; i < n; i++ {
+		rec := array + 8*i
+		if rec < 0 || rec+8 > len(eblc) {
+			return nil, errMalformedStrike
+		}
+		first := int(binary.BigEndian.Uint16(eblc[rec:]))
+		last := int(binary.BigEndian.Uint16(eblc[rec+2:]))
+		sub := array + int(binary.BigEndian.Uint32(eblc[rec+4:]))
+		if last < first || sub < 0 || sub+8 > len(eblc) {
+			return nil, errMalformedStrike
+		}
+		indexFormat := binary.BigEndian.Uint16(eblc[sub:])
+		imageFormat := binary.BigEndian.Uint16(eblc[sub+2:])
+		imageData := int(binary.BigEndian.Uint32(eblc[sub+4:]))
+		if imageFormat != 1 && imageFormat != 2 {
+			return nil, fmt.Errorf("the image format %d of glyphs %d-%d is not supported", imageFormat, first, last)
+		}
+		var offsets []int
+		switch indexFormat {
+		case 1, 3:
+			entry := 4
+			if indexFormat == 3 {
+				entry = 2
+			}
+			for j := 0; j < last-first+2; j++ {
+				p := sub + 8 + entry*j
+				if p+entry > len(eblc) {
+					return nil, errMalformedStrike
+				}
+				if entry == 4 {
+					offsets = append(offsets, int(binary.BigEndian.Uint32(eblc[p:])))
+				} else {
+					offsets = append(offsets, int(binary.BigEndian.Uint16(eblc[p:])))
+				}
+			}
+		default:
+			return nil, fmt.Errorf("the index format %d of glyphs %d-%d is not supported", indexFormat, first, last)
+		}
+		for j := 0; j <= last-first; j++ {
+			start, end := imageData+offsets[j], imageData+offsets[j+1]
+			if start == end {
+				s[sfnt.GlyphIndex(first+j)] = &strikeBitmap{} // blank
+				continue
+			}
+			if start < 0 || end < start || end > len(ebdt) {
+				return nil, errMalformedStrike
+			}
+			b, err := parseStrikeBitmap(ebdt[start:end], imageFormat)
+			if err != nil {
+				return nil, err
+			}
+			s[sfnt.GlyphIndex(first+j)] = b
+		}
+	}
+	return s, nil
+}
+
+// parseStrikeBitmap reads an EBDT glyph with small metrics, its rows byte
+// aligned in image format 1 and bit aligned in format 2.
+func parseStrikeBitmap(data []byte, format uint16) (*strikeBitmap, error) {
+	if len(data) < 5 {
+		return nil, errMalformedStrike
+	}
+	b := &strikeBitmap{
+		height:   int(data[0]),
+		width:    int(data[1]),
+		bearingX: int(int8(data[2])),
+		bearingY: int(int8(data[3])),
+	}
+	data = data[5:]
+	if format == 2 {
+		if len(data)*8 < b.width*b.height {
+			return nil, errMalformedStrike
+		}
+		b.data = data
+		return b, nil
+	}
+	rowBytes := (b.width + 7) / 8
+	if len(data) < rowBytes*b.height {
+		return nil, errMalformedStrike
+	}
+	b.data = make([]byte, (b.width*b.height+7)/8)
+	for y := 0; y < b.height; y++ {
+		for x := 0; x < b.width; x++ {
+			if data[y*rowBytes+x/8]&(0x80>>(x%8)) != 0 {
+				i := y*b.width + x
+				b.data[i/8] |= 0x80 >> (i % 8)
+			}
+		}
+	}
+	return b, nil
+}
+
+// strikeHeader places the strike bitmaps of the runes of glyphs in cells
+// like the glyphs: the pen at the x offset, the baseline at the top of the
+// glyph. Runes without a bitmap are left out.
+func strikeHeader(f *sfnt.Font, s strike, glyphs []glyph, opts *options) (*header, error) {
+	h := &header{name: "strike", glyphs: map[rune]glyph{}}
+	h.width, h.height = opts.storedCell()
+	for _, g := range glyphs {
+		x, err := f.GlyphIndex(nil, g.rune)
+		if err != nil {
+			return nil, err
+		}
+		if vx, ok := opts.variants[g.rune]; ok {
+			x = vx
+		}
+		b, ok := s[x]
+		if !ok || x == 0 {
+			continue
+		}
+		sg := glyph{rune: g.rune, width: g.width, height: g.height, bpp: 1}
+		sg.data = make([]byte, sg.rowBytes()*sg.height)
+		for by := 0; by < b.height; by++ {
+			for bx := 0; bx < b.width; bx++ {
+				cx, cy := opts.Xoffset+b.bearingX+bx, g.top-b.bearingY+by
+				if b.at(bx, by) && cx >= 0 && cx < sg.width && cy >= 0 && cy < sg.height {
+					putLevel(sg.data[cy*sg.rowBytes():], cx, 1, 1, false)
+				}
+			}
+		}
+		h.glyphs[g.rune] = sg
+	}
+	return h, nil
+}
+
+// outlineHeader returns the rendered glyphs for the comparison with a strike.
+func outlineHeader(glyphs []glyph, opts *options) *header {
+	h := &header{name: "outline", glyphs: map[rune]glyph{}}
+	h.width, h.height = opts.storedCell()
+	for _, g := range glyphs {
+		h.glyphs[g.rune] = g
+	}
+	return h
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// stripRect is the slice of the strip holding a glyph.
+type stripRect struct {
+	x, width int
+	left     int // column of the slice in the cell
+}
+
+// stripRects returns the slice of every glyph in a strip of their ink
+// columns placed side by side, and the width of the strip.
+func stripRects(glyphs []glyph) ([]stripRect, int) {
+	rects := make([]stripRect, len(glyphs))
+	x := 0
+	for i := range glyphs {
+		first, last, ok := glyphs[i].inkColumns()
+		if !ok {
+			rects[i] = stripRect{x: x}
+			continue
+		}
+		rects[i] = stripRect{x, last - first + 1, first}
+		x += rects[i].width
+	}
+	return rects, x
+}
+
+// stripRows packs the glyphs into the rows of a strip of the given width.
+func stripRows(glyphs []glyph, rects []stripRect, width int, opts *options) [][]byte {
+	_, height := opts.storedCell()
+	rowBytes := (width*opts.Bpp + 7) / 8
+	rows := make([][]byte, height)
+	for y := range rows {
+		rows[y] = make([]byte, rowBytes)
+		for i := range glyphs {
+			g, r := &glyphs[i], rects[i]
+			for x := 0; x < r.width; x++ {
+				putLevel(rows[y], r.x+x, g.level(r.left+x, y), g.bpp, g.lowNibbleFirst)
+			}
+		}
+	}
+	return rows
+}
+
+// writeStrip writes the ink of all glyphs side by side as one wide bitmap
+// and a table of the slice and the advance of every glyph, for blitters
+// copying rectangles out of a single bitmap.
+func writeStrip(w io.Writer, glyphs []glyph, opts *options) error {
+	out := bufio.NewWriter(w)
+	rects, width := stripRects(glyphs)
+	rows := stripRows(glyphs, rects, width, opts)
+
+	fmt.Fprint(out, `#include <stdint.h>
+#if defined(__AVR__) || defined(ARDUINO_ARCH_SAMD)
+#include <avr/pgmspace.h>
+#elif defined(ESP8266) || defined(ESP32)
+#include <pgmspace.h>
+#endif
+#ifndef PROGMEM
+#define PROGMEM
+#endif
+
+`)
+	fmt.Fprintf(out, "#define FontCustom_StripWidth %d%s\n", width, opts.trailingComment("in pixels"))
+	fmt.Fprintf(out, "#define FontCustom_StripHeight %d\n", len(rows))
+	fmt.Fprintf(out, "#define FontCustom_StripRowBytes %d\n", (width*opts.Bpp+7)/8)
+	if opts.Bpp != 1 {
+		fmt.Fprintf(out, "#define FontCustom_Bpp %d%s\n", opts.Bpp, opts.trailingComment(opts.NibbleOrder+" nibble first"))
+		fmt.Fprintf(out, "#define FontCustom_LowNibbleFirst %d%s\n", opts.lowNibbleFirst(), opts.trailingComment("1 if the first pixel of a byte is its low nibble"))
+	}
+	fmt.Fprintln(out)
+	if c := opts.blockComment("Based on font " + string(opts.Font)); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, "const uint8_t FontCustom_Strip [] PROGMEM%s =\n{\n", opts.alignAttr())
+	for y, row := range rows {
+		if c := opts.comment(fmt.Sprintf("row %d", y)); c != "" {
+			fmt.Fprintf(out, "  %s\n", c)
+		}
+		writeBytes(out, row, "  ")
+	}
+	fmt.Fprint(out, "};")
+	if runes := glyphRunes(glyphs); !contiguousASCII(runes) {
+		writeCodepoints(out, runes)
+	}
+
+	var xs, columns []int
+	for _, r := range rects {
+		xs, columns = append(xs, r.x), append(columns, r.width, r.left)
+	}
+	advance := "uint8_t"
+	for _, g := range glyphs {
+		if g.advance > 255 || g.advance < 0 {
+			advance = "int16_t"
+		}
+	}
+	fmt.Fprint(out, "\n\n")
+	if c := opts.blockComment("glyph i is the slice [x, x + width) of every row of FontCustom_Strip, drawn left pixels right of the left edge of its cell"); c != "" {
+		fmt.Fprintln(out, c)
+	}
+	fmt.Fprintf(out, "typedef struct {\n  %s x;\n  %s width;\n  %[2]s left;\n  %s advance;%s\n} FontCustom_StripRect;\n\n",
+		uintType(xs), uintType(columns), advance, opts.trailingComment("in pixels"))
+	fmt.Fprint(out, "const FontCustom_StripRect FontCustom_StripRects [] PROGMEM =\n{\n")
+	for i, r := range rects {
+		g := &glyphs[i]
+		fmt.Fprintf(out, "  {%d, %d, %d, %d},", r.x, r.width, r.left, g.advance)
+		if c := opts.comment(glyphComment(g)); c != "" {
+			fmt.Fprintf(out, "  %s", c)
+		}
+		fmt.Fprintln(out)
+	}
+	fmt.Fprint(out, "};")
+	if opts.EmbedRenderParams {
+		writeRenderParams(out, opts)
+	}
+	fmt.Fprintf(out, "\n\nconst uint16_t FontCustom_StripGlyphCount = %d;\n", len(glyphs))
+	return out.Flush()
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// The emitters write their output with an indentation of two spaces per
+// level and the braces placed as in the classic sFONT files. A
+// styleWriter converts that into the configured indentation and brace
+// style line by line.
+type styleWriter struct {
+	w       io.Writer
+	unit    string // replaces each two spaces of leading indentation
+	brace   string // "default", "attach" or "break"
+	partial []byte // incomplete line
+	pending *string
+	err     error
+}
+
+// parseIndent parses an indentation given as a number of spaces or tab
+// into the string of one level.
+func parseIndent(s string) (string, error) {
+	if s == "tab" {
+		return "\t", nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return "", fmt.Errorf("indent %q must be a number of spaces or tab", s)
+	}
+	return strings.Repeat(" ", n), nil
+}
+
+// newStyleWriter returns w if the options use the default style.
+func newStyleWriter(w io.Writer, opts *options) io.Writer {
+	unit := "  "
+	if opts.indent != nil {
+		unit = *opts.indent
+	}
+	if unit == "  " && opts.BraceStyle == "default" {
+		return w
+	}
+	return &styleWriter{w: w, unit: unit, brace: opts.BraceStyle}
+}
+
+func (s *styleWriter) Write(p []byte) (int, error) {
+	s.partial = append(s.partial, p...)
+	for {
+		i := bytes.IndexByte(s.partial, '\n')
+		if i < 0 {
+			break
+		}
+		s.line(string(s.partial[:i]))
+		s.partial = s.partial[i+1:]
+	}
+	return len(p), s.err
+}
+
+// Close writes the remaining output.
+func (s *styleWriter) Close() error {
+	s.flushPending()
+	if len(s.partial) > 0 {
+		s.emit(string(s.partial))
+		s.partial = nil
+	}
+	return s.err
+}
+
+func (s *styleWriter) line(l string) {
+	trimmed := strings.TrimLeft(l, " ")
+	depth := (len(l) - len(trimmed)) / 2
+	indent := strings.Repeat(s.unit, depth) + strings.Repeat(" ", (len(l)-len(trimmed))%2)
+	switch {
+	case s.brace == "attach" && trimmed == "{" && s.pending != nil:
+		*s.pending += " {"
+		return
+	case s.brace == "break" && trimmed != "{" && strings.HasSuffix(trimmed, " {"):
+		s.flushPending()
+		s.emit(indent + strings.TrimSuffix(trimmed, " {") + "\n")
+		s.hold(indent + "{")
+		return
+	}
+	s.flushPending()
+	s.hold(indent + trimmed)
+}
+
+// hold keeps a line back until it is known whether the next line opens a brace.
+func (s *styleWriter) hold(l string) {
+	s.pending = &l
+}
+
+func (s *styleWriter) flushPending() {
+	if s.pending != nil {
+		s.emit(*s.pending + "\n")
+		s.pending = nil
+	}
+}
+
+func (s *styleWriter) emit(l string) {
+	if s.err == nil {
+		_, s.err = io.WriteString(s.w, l)
+	}
+}
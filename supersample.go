@@ -0,0 +1,137 @@
+package main
+
+import "math"
+
+// linearLUT maps an 8 bit coverage value to linear light using the sRGB
+// transfer function.
+var linearLUT = func() (lut [256]float64) {
+	for i := range lut {
+		c := float64(i) / 255
+		if c <= 0.04045 {
+			lut[i] = c / 12.92
+		} else {
+			lut[i] = math.Pow((c+0.055)/1.055, 2.4)
+		}
+	}
+	return lut
+}()
+
+// fromLinear converts linear light back to an 8 bit coverage value.
+func fromLinear(l float64) uint8 {
+	var c float64
+	if l <= 0.0031308 {
+		c = l * 12.92
+	} else {
+		c = 1.055*math.Pow(l, 1/2.4) - 0.055
+	}
+	return uint8(math.Round(math.Max(0, math.Min(1, c)) * 255))
+}
+
+// tap is a sample offset of a downsample kernel and its weight.
+type tap struct {
+	off int
+	w   float64
+}
+
+// kernel returns the 1D taps of the downsample filter for a scale of s.
+// The offsets are relative to the first sample of a destination pixel and
+// the weights depend on the distance to the center of the pixel, in
+// destination pixels: the triangle falls off to 0 at a distance of 1, the
+// gaussian has a sigma of 0.5 and is cut off at 1.5.
+func kernel(filter string, s int) []tap {
+	var taps []tap
+	for off := -2 * s; off < 3*s; off++ {
+		d := math.Abs((float64(off)+0.5)/float64(s) - 0.5)
+		var w float64
+		switch filter {
+		case "triangle":
+			w = 1 - d
+		case "gaussian":
+			if d <= 1.5 {
+				w = math.Exp(-d * d / (2 * 0.5 * 0.5))
+			}
+		default:
+			if d < 0.5 {
+				w = 1
+			}
+		}
+		if w > 0 {
+			taps = append(taps, tap{off, w})
+		}
+	}
+	return taps
+}
+
+// filterDownsample reduces the supersampled cell into the destination
+// cell with the separable kernel of the downsample filter. Samples outside
+// of the cell are blank.
+func (r *renderer) filterDownsample() {
+	s := r.opts.Scale
+	taps := kernel(r.opts.Downsample, s)
+	total := 0.0
+	for _, t := range taps {
+		total += t.w
+	}
+	total *= total
+	w, h := r.ss.Rect.Dx(), r.ss.Rect.Dy()
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			sum := 0.0
+			for _, ty := range taps {
+				sy := y*s + ty.off
+				if sy < 0 || sy >= h {
+					continue
+				}
+				row := r.ss.Pix[sy*r.ss.Stride:]
+				for _, tx := range taps {
+					sx := x*s + tx.off
+					if sx < 0 || sx >= w {
+						continue
+					}
+					v := float64(row[sx]) / 255
+					if r.opts.LinearDownsample {
+						v = linearLUT[row[sx]]
+					}
+					sum += v * ty.w * tx.w
+				}
+			}
+			a := uint8(math.Round(math.Min(1, sum/total) * 255))
+			if r.opts.LinearDownsample {
+				a = fromLinear(sum / total)
+			}
+			r.dst.Pix[y*r.dst.Stride+x] = a
+		}
+	}
+}
+
+// downsample reduces the supersampled cell into the destination cell by
+// averaging each block of scale*scale samples, or with the configured
+// filter.
+func (r *renderer) downsample() {
+	if r.opts.Downsample != "box" {
+		r.filterDownsample()
+		return
+	}
+	s := r.opts.Scale
+	n := s * s
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			sum, lin := 0, 0.0
+			for sy := 0; sy < s; sy++ {
+				row := r.ss.Pix[(y*s+sy)*r.ss.Stride+x*s:]
+				for sx := 0; sx < s; sx++ {
+					if r.opts.LinearDownsample {
+						lin += linearLUT[row[sx]]
+					} else {
+						sum += int(row[sx])
+					}
+				}
+			}
+			a := uint8((sum + n/2) / n)
+			if r.opts.LinearDownsample {
+				a = fromLinear(lin / float64(n))
+			}
+			r.dst.Pix[y*r.dst.Stride+x] = a
+		}
+	}
+}
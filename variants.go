@@ -0,0 +1,222 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// The sfnt package only resolves single codepoints, so the Unicode
+// Variation Sequences subtable (cmap format 14) is read here directly. See
+// https://learn.microsoft.com/en-us/typography/opentype/spec/cmap#format-14-unicode-variation-sequences
+
+var errMalformedCmap = errors.New("malformed cmap table")
+
+// uvsRange is a range of base codepoints using their default glyph.
+type uvsRange struct {
+	start, end rune
+}
+
+// uvsTable holds the variation sequences of a font.
+type uvsTable struct {
+	// nonDefault maps a base codepoint and a selector to a glyph.
+	nonDefault map[[2]rune]sfnt.GlyphIndex
+	// defaults lists per selector the base codepoints which keep the glyph
+	// of the regular cmap.
+	defaults map[rune][]uvsRange
+}
+
+// lookup resolves a variation sequence. If def is true the sequence uses
+// the default glyph of the base codepoint.
+func (t *uvsTable) lookup(base, selector rune) (x sfnt.GlyphIndex, def, ok bool) {
+	if x, ok := t.nonDefault[[2]rune{base, selector}]; ok {
+		return x, false, true
+	}
+	for _, r := range t.defaults[selector] {
+		if base >= r.start && base <= r.end {
+			return 0, true, true
+		}
+	}
+	return 0, false, false
+}
+
+// sfntTable returns the table tag of the font data src, nil if the font
+// has no such table. It reports false if the table directory is malformed.
+func sfntTable(src []byte, tag string) ([]byte, bool) {
+	if len(src) < 12 {
+		return nil, false
+	}
+	numTables := int(binary.BigEndian.Uint16(src[4:]))
+	for i := 0; i < numTables; i++ {
+		rec := 12 + 16*i
+		if rec+16 > len(src) {
+			return nil, false
+		}
+		if string(src[rec:rec+4]) != tag {
+			continue
+		}
+		offset := int(binary.BigEndian.Uint32(src[rec+8:]))
+		length := int(binary.BigEndian.Uint32(src[rec+12:]))
+		if offset < 0 || length < 0 || offset+length > len(src) {
+			return nil, false
+		}
+		return src[offset : offset+length], true
+	}
+	return nil, true
+}
+
+func u24(b []byte) rune {
+	return rune(b[0])<<16 | rune(b[1])<<8 | rune(b[2])
+}
+
+// readUVS parses the format 14 cmap subtable of the font data src. A font
+// without variation sequences results in an empty table.
+func readUVS(src []byte) (*uvsTable, error) {
+	t := &uvsTable{
+		nonDefault: map[[2]rune]sfnt.GlyphIndex{},
+		defaults:   map[rune][]uvsRange{},
+	}
+	cmap, ok := sfntTable(src, "cmap")
+	if !ok {
+		return nil, errMalformedCmap
+	}
+	if len(cmap) < 4 {
+		return t, nil
+	}
+	numSubtables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := 0; i < numSubtables; i++ {
+		rec := 4 + 8*i
+		if rec+8 > len(cmap) {
+			return nil, errMalformedCmap
+		}
+		pid := binary.BigEndian.Uint16(cmap[rec:])
+		psid := binary.BigEndian.Uint16(cmap[rec+2:])
+		offset := int(binary.BigEndian.Uint32(cmap[rec+4:]))
+		if pid != 0 || psid != 5 {
+			continue
+		}
+		if offset+10 > len(cmap) || binary.BigEndian.Uint16(cmap[offset:]) != 14 {
+			return nil, errMalformedCmap
+		}
+		if err := t.parse(cmap[offset:]); err != nil {
+			return nil, err
+		}
+		break
+	}
+	return t, nil
+}
+
+// parse reads a format 14 subtable starting at the beginning of sub.
+func (t *uvsTable) parse(sub []byte) error {
+	if len(sub) < 10 {
+		return errMalformedCmap
+	}
+	if n := int(binary.BigEndian.Uint32(sub[2:])); n < len(sub) {
+		sub = sub[:n]
+	}
+	if len(sub) < 10 {
+		return errMalformedCmap
+	}
+	numRecords := int(binary.BigEndian.Uint32(sub[6:]))
+	for i := 0; i < numRecords; i++ {
+		rec := 10 + 11*i
+		if rec+11 > len(sub) {
+			return errMalformedCmap
+		}
+		selector := u24(sub[rec:])
+		defOffset := int(binary.BigEndian.Uint32(sub[rec+3:]))
+		nonDefOffset := int(binary.BigEndian.Uint32(sub[rec+7:]))
+		if defOffset != 0 {
+			if defOffset+4 > len(sub) {
+				return errMalformedCmap
+			}
+			n := int(binary.BigEndian.Uint32(sub[defOffset:]))
+			for j := 0; j < n; j++ {
+				p := defOffset + 4 + 4*j
+				if p+4 > len(sub) {
+					return errMalformedCmap
+				}
+				start := u24(sub[p:])
+				t.defaults[selector] = append(t.defaults[selector], uvsRange{start, start + rune(sub[p+3])})
+			}
+		}
+		if nonDefOffset != 0 {
+			if nonDefOffset+4 > len(sub) {
+				return errMalformedCmap
+			}
+			n := int(binary.BigEndian.Uint32(sub[nonDefOffset:]))
+			for j := 0; j < n; j++ {
+				p := nonDefOffset + 4 + 5*j
+				if p+5 > len(sub) {
+					return errMalformedCmap
+				}
+				base := u24(sub[p:])
+				t.nonDefault[[2]rune{base, selector}] = sfnt.GlyphIndex(binary.BigEndian.Uint16(sub[p+3:]))
+			}
+		}
+	}
+	return nil
+}
+
+// isVariationSelector reports whether v is one of the Unicode variation selectors.
+func isVariationSelector(v rune) bool {
+	return (v >= 0xFE00 && v <= 0xFE0F) || (v >= 0xE0100 && v <= 0xE01EF)
+}
+
+// variantFields splits a variation sequence at spaces and commas.
+func variantFields(s string) []string {
+	return strings.FieldsFunc(s, func(c rune) bool { return c == ',' || unicode.IsSpace(c) })
+}
+
+// parseVariant parses a variation sequence such as "U+2764 U+FE0F" or
+// "U+2764,U+FE0F".
+func parseVariant(s string) (base, selector rune, err error) {
+	fields := variantFields(s)
+	if len(fields) != 2 {
+		return 0, 0, fmt.Errorf("variation sequence %q must consist of a codepoint and a selector", s)
+	}
+	if base, err = parseCodepoint(fields[0]); err != nil {
+		return 0, 0, err
+	}
+	if selector, err = parseCodepoint(fields[1]); err != nil {
+		return 0, 0, err
+	}
+	if !isVariationSelector(selector) {
+		return 0, 0, fmt.Errorf("U+%04X is not a variation selector", selector)
+	}
+	return base, selector, nil
+}
+
+// resolveVariants maps the base codepoint of every variation sequence in
+// seqs to the glyph it selects in the font data src. A base codepoint may
+// only be given with one selector.
+func resolveVariants(src []byte, seqs []string) (map[rune]sfnt.GlyphIndex, error) {
+	t, err := readUVS(src)
+	if err != nil {
+		return nil, err
+	}
+	variants := map[rune]sfnt.GlyphIndex{}
+	seen := map[rune]rune{} // selector by base codepoint
+	for _, s := range seqs {
+		base, selector, err := parseVariant(s)
+		if err != nil {
+			return nil, err
+		}
+		x, def, ok := t.lookup(base, selector)
+		if !ok {
+			return nil, fmt.Errorf("the font has no variant U+%04X U+%04X", base, selector)
+		}
+		if _, dup := seen[base]; dup && seen[base] != selector {
+			return nil, fmt.Errorf("U+%04X has variants with the selectors U+%04X and U+%04X", base, seen[base], selector)
+		}
+		seen[base] = selector
+		if !def {
+			variants[base] = x
+		}
+	}
+	return variants, nil
+}
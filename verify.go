@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// pixel reports whether the pixel at x,y of the glyph is set. With 4 bpp
+// a pixel is set at the level of the 1 bpp threshold.
+func (g *glyph) pixel(x, y int) bool {
+	if g.bpp == 4 {
+		return g.level(x, y) >= 4
+	}
+	return g.level(x, y) != 0
+}
+
+// image returns the glyph as black ink on a white background.
+func (g *glyph) image() *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, g.width, g.height))
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			if !g.pixel(x, y) {
+				img.SetGray(x, y, color.Gray{Y: 0xFF})
+			}
+		}
+	}
+	return img
+}
+
+// referenceName returns the file name of the reference image of v.
+func referenceName(v rune) string {
+	return fmt.Sprintf("U+%04X.png", v)
+}
+
+// compareImage returns the number of pixels in which the glyph differs from
+// img. Dark pixels of img are ink.
+func (g *glyph) compareImage(img image.Image) (int, error) {
+	b := img.Bounds()
+	if b.Dx() != g.width || b.Dy() != g.height {
+		return 0, fmt.Errorf("reference is %dx%d, glyph is %dx%d", b.Dx(), b.Dy(), g.width, g.height)
+	}
+	diff := 0
+	for y := 0; y < g.height; y++ {
+		for x := 0; x < g.width; x++ {
+			ref := color.GrayModel.Convert(img.At(b.Min.X+x, b.Min.Y+y)).(color.Gray).Y < 0x80
+			if ref != g.pixel(x, y) {
+				diff++
+			}
+		}
+	}
+	return diff, nil
+}
+
+// verifyGlyphs compares every glyph with its reference image in dir and
+// logs every glyph differing in more than tolerance pixels. It returns the
+// number of mismatching glyphs.
+func verifyGlyphs(dir string, glyphs []glyph, tolerance int) int {
+	failed := 0
+	for _, g := range glyphs {
+		name := filepath.Join(dir, referenceName(g.rune))
+		diff, err := compareFile(&g, name)
+		switch {
+		case err != nil:
+			log.Printf("verify U+%04X: %v", g.rune, err)
+		case diff > tolerance:
+			log.Printf("verify U+%04X: %d pixels differ from %s", g.rune, diff, name)
+		default:
+			continue
+		}
+		failed++
+	}
+	return failed
+}
+
+func compareFile(g *glyph, name string) (int, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %v", name, err)
+	}
+	return g.compareImage(img)
+}
+
+// selfCheck renders the runes a second time and compares the glyphs and
+// the written output with the first render. It logs every divergence and
+// returns their number.
+func selfCheck(f *sfnt.Font, runes []rune, glyphs []glyph, opts *options) (int, error) {
+	again, err := renderGlyphs(f, runes, opts)
+	if err != nil {
+		return 0, err
+	}
+	if len(again) != len(glyphs) {
+		log.Printf("self-check: %d glyphs in the first render, %d in the second", len(glyphs), len(again))
+		return 1, nil
+	}
+	failed := 0
+	for i := range glyphs {
+		a, b := &glyphs[i], &again[i]
+		if a.rune != b.rune || !bytes.Equal(a.data, b.data) || !bytes.Equal(a.stored, b.stored) {
+			log.Printf("self-check U+%04X: the renders differ", a.rune)
+			failed++
+		}
+	}
+	var first, second bytes.Buffer
+	if err := write(&first, glyphs, opts); err != nil {
+		return 0, err
+	}
+	if err := write(&second, again, opts); err != nil {
+		return 0, err
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		log.Print("self-check: the written outputs differ")
+		failed++
+	}
+	return failed, nil
+}